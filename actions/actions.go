@@ -14,12 +14,16 @@
 // working with the actions.
 //
 // See: https://yalter.github.io/niri/niri_ipc/enum.Action.html# for more details.
+//
+// In addition, the nirimgr actions in the NirimgrActionRegistry are performed by nirimgr itself,
+// but can be used everywhere a niri action can.
 package actions
 
 import (
 	"encoding/json"
 	"log/slog"
 	"reflect"
+	"strings"
 
 	"github.com/soderluk/nirimgr/internal/common"
 	"github.com/soderluk/nirimgr/models"
@@ -70,9 +74,57 @@ func HandleDynamicIDs(a Action, possibleKeys models.PossibleKeys) Action {
 	return a
 }
 
+// ApplyTarget sets the IDs of the target window and workspace on the action.
+//
+// The window ID is set to the ID and WindowID fields, and the workspace ID to the WorkspaceID field
+// and the workspace reference fields. Unlike HandleDynamicIDs, the fields are set regardless of their value,
+// unless they're given in the params of the action, or the ID of the target is 0.
+func ApplyTarget(a Action, params json.RawMessage, windowID, workspaceID uint64) Action {
+	value := reflect.ValueOf(a)
+	// nolint:govet
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return a
+	}
+	var given map[string]json.RawMessage
+	_ = json.Unmarshal(params, &given)
+
+	valueType := value.Type()
+	for i := range value.NumField() {
+		field := value.Field(i)
+		structField := valueType.Field(i)
+		jsonName, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+		if _, ok := given[jsonName]; ok || !field.CanSet() {
+			continue
+		}
+		switch {
+		case structField.Type == reflect.TypeFor[WorkspaceReferenceArg]():
+			if workspaceID != 0 {
+				field.Set(reflect.ValueOf(WorkspaceReferenceArg{ID: workspaceID}))
+			}
+		case structField.Name == "ID" || structField.Name == "WindowID":
+			if windowID != 0 && field.Kind() == reflect.Uint64 {
+				field.SetUint(windowID)
+			}
+		case structField.Name == "WorkspaceID":
+			if workspaceID != 0 && field.Kind() == reflect.Uint64 {
+				field.SetUint(workspaceID)
+			}
+		}
+	}
+	return a
+}
+
 // FromRegistry returns the populated model from the ActionRegistry by given name.
+//
+// If niri doesn't have the action, the nirimgr actions in the NirimgrActionRegistry are checked.
 func FromRegistry(name string, data []byte) Action {
 	model, ok := ActionRegistry[name]
+	if !ok {
+		model, ok = NirimgrActionRegistry[name]
+	}
 	if !ok {
 		slog.Error("Could not get action model for action", "name", name)
 		return nil
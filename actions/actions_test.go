@@ -94,6 +94,27 @@ func TestHandleDynamicIDs(t *testing.T) {
 	}
 }
 
+func TestApplyTarget(t *testing.T) {
+	params := json.RawMessage(`{"reference": {"Index": 2}}`)
+	a := FromRegistry("MoveWindowToWorkspace", params)
+	a = ApplyTarget(a, params, 5, 7)
+	// The given reference is kept, the window is set.
+	assert.Equal(t, &MoveWindowToWorkspace{
+		AName:     AName{Name: "MoveWindowToWorkspace"},
+		WindowID:  5,
+		Reference: WorkspaceReferenceArg{Index: 2},
+	}, a)
+
+	// Unlike the dynamic IDs, the target replaces a non-zero reference that wasn't given in the params.
+	a = ApplyTarget(&SetWorkspaceName{AName: AName{Name: "SetWorkspaceName"}, Workspace: WorkspaceReferenceArg{Name: "old"}}, nil, 0, 7)
+	assert.Equal(t, WorkspaceReferenceArg{ID: 7}, a.(*SetWorkspaceName).Workspace)
+
+	// A given ID of 0 is kept.
+	params = json.RawMessage(`{"id": 0}`)
+	a = ApplyTarget(FromRegistry("FocusWindow", params), params, 5, 7)
+	assert.Equal(t, uint64(0), a.(*FocusWindow).ID)
+}
+
 func TestFromRegistryMissingAction(t *testing.T) {
 	// Should return nil if action type is not registered
 	payload := map[string]any{"id": 1}
@@ -1,5 +1,7 @@
 package actions
 
+import "encoding/json"
+
 // If more actions are added in Niri, we must define them here, and add them to the ActionRegistry.
 
 // Action is the "base" interface for all the actions.
@@ -800,6 +802,29 @@ type MoveFloatingWindow struct {
 	Y PositionChange `json:"y"`
 }
 
+// MarshalJSON makes sure both position changes are valid for niri.
+//
+// Niri requires both the x and y position changes, so an empty PositionChange is sent as
+// {"AdjustFixed": 0}, i.e. the window is not moved along that axis.
+func (m MoveFloatingWindow) MarshalJSON() ([]byte, error) {
+	type moveFloatingWindow MoveFloatingWindow
+	positionChange := func(p PositionChange) any {
+		if p == (PositionChange{}) {
+			return map[string]float64{"AdjustFixed": 0}
+		}
+		return p
+	}
+	return json.Marshal(struct {
+		moveFloatingWindow
+		X any `json:"x"`
+		Y any `json:"y"`
+	}{
+		moveFloatingWindow: moveFloatingWindow(m),
+		X:                  positionChange(m.X),
+		Y:                  positionChange(m.Y),
+	})
+}
+
 // ToggleWindowRuleOpacity toggles the opacity of a window.
 type ToggleWindowRuleOpacity struct {
 	AName
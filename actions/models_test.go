@@ -1,6 +1,7 @@
 package actions
 
 import (
+	"encoding/json"
 	"testing"
 )
 
@@ -124,3 +125,15 @@ func TestFocusWindowInColumn(t *testing.T) {
 		t.Errorf("expected Index 2, got %d", a.Index)
 	}
 }
+
+func TestMoveFloatingWindowMarshal(t *testing.T) {
+	a := MoveFloatingWindow{AName: AName{"MoveFloatingWindow"}, ID: 3, X: PositionChange{SetFixed: 10}}
+	b, err := json.Marshal(a)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	want := `{"Name":"MoveFloatingWindow","id":3,"x":{"SetFixed":10},"y":{"AdjustFixed":0}}`
+	if string(b) != want {
+		t.Errorf("expected %s, got %s", want, string(b))
+	}
+}
@@ -0,0 +1,230 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/soderluk/nirimgr/internal/common"
+)
+
+// Exec runs a command detached from nirimgr.
+//
+// The command is run directly, not through a shell. The ID, WindowID and WorkspaceID are set dynamically
+// from the matched window, workspace or event, and exported to the command as NIRIMGR_ID, NIRIMGR_WINDOW_ID
+// and NIRIMGR_WORKSPACE_ID. The Env values can refer to them, or any other environment variable, with $VAR or ${VAR}.
+type Exec struct {
+	AName
+	// Command is the command and its arguments.
+	Command []string `json:"command"`
+	// Dir is the working directory of the command. Defaults to the working directory of nirimgr.
+	Dir string `json:"dir,omitempty"`
+	// Env is the extra environment variables for the command.
+	Env map[string]string `json:"env,omitempty"`
+	// ID is the ID of the matched window or workspace.
+	ID uint64 `json:"id,omitempty"`
+	// WindowID is the ID of the window.
+	WindowID uint64 `json:"window_id,omitempty"`
+	// WorkspaceID is the ID of the workspace.
+	WorkspaceID uint64 `json:"workspace_id,omitempty"`
+}
+
+// Notify sends a desktop notification with notify-send.
+type Notify struct {
+	AName
+	// Summary is the title of the notification.
+	Summary string `json:"summary"`
+	// Body is the text of the notification.
+	Body string `json:"body,omitempty"`
+	// Urgency is one of low, normal or critical. Defaults to normal.
+	Urgency string `json:"urgency,omitempty"`
+}
+
+// Sleep pauses before performing the next step, e.g. to wait for a window to settle.
+//
+// NOTE: The events daemon only sleeps in steps, since sleeping elsewhere would block the other events.
+type Sleep struct {
+	AName
+	// Duration is the duration to sleep, e.g. "200ms".
+	Duration string `json:"duration"`
+}
+
+// Log writes a message to the nirimgr log.
+type Log struct {
+	AName
+	// Message is the message to log.
+	Message string `json:"message"`
+	// Level is one of debug, info, warn or error. Defaults to info.
+	Level string `json:"level,omitempty"`
+	// ID is the ID of the matched window or workspace, logged with the message.
+	ID uint64 `json:"id,omitempty"`
+}
+
+// ScratchpadMove moves a window to the scratchpad workspace, like `nirimgr scratch move`.
+type ScratchpadMove struct {
+	AName
+	// ID is the ID of the window to move. If omitted, uses the focused window.
+	ID uint64 `json:"id,omitempty"`
+}
+
+// ScratchpadShow shows a window from the scratchpad workspace, like `nirimgr scratch show`.
+type ScratchpadShow struct {
+	AName
+	// Cycle hides the shown scratchpad window, and shows the next one.
+	Cycle bool `json:"cycle,omitempty"`
+}
+
+// FloatingPlace places a floating window at a position on the screen, like `nirimgr floating place`.
+type FloatingPlace struct {
+	AName
+	// ID is the ID of the window to place. If omitted, uses the focused window.
+	ID uint64 `json:"id,omitempty"`
+	// Position is the position to place the window at, e.g. "center" or "left-half".
+	Position string `json:"position"`
+}
+
+// NirimgrActionRegistry contains the actions nirimgr performs itself, instead of sending them to niri.
+//
+// They can be used everywhere the niri actions from the ActionRegistry can. The actions depending on the
+// nirimgr commands get their handler registered by the command with RegisterNirimgrHandler.
+var NirimgrActionRegistry = map[string]func() Action{
+	"Exec":           func() Action { return &Exec{AName: AName{Name: "Exec"}} },
+	"FloatingPlace":  func() Action { return &FloatingPlace{AName: AName{Name: "FloatingPlace"}} },
+	"Log":            func() Action { return &Log{AName: AName{Name: "Log"}} },
+	"Notify":         func() Action { return &Notify{AName: AName{Name: "Notify"}} },
+	"ScratchpadMove": func() Action { return &ScratchpadMove{AName: AName{Name: "ScratchpadMove"}} },
+	"ScratchpadShow": func() Action { return &ScratchpadShow{AName: AName{Name: "ScratchpadShow"}} },
+	"Sleep":          func() Action { return &Sleep{AName: AName{Name: "Sleep"}} },
+}
+
+// NirimgrHandler performs a nirimgr action.
+type NirimgrHandler func(Action) error
+
+// nirimgrHandlers contains the handlers of the nirimgr actions by the action name.
+var nirimgrHandlers = map[string]NirimgrHandler{
+	"Exec":   performExec,
+	"Log":    performLog,
+	"Notify": performNotify,
+	"Sleep":  performSleep,
+}
+
+// RegisterNirimgrHandler sets the handler of the nirimgr action with the given name.
+func RegisterNirimgrHandler(name string, handler NirimgrHandler) {
+	nirimgrHandlers[name] = handler
+}
+
+// IsNirimgrAction tells whether the action with the given name is performed by nirimgr.
+func IsNirimgrAction(name string) bool {
+	_, ok := NirimgrActionRegistry[name]
+	return ok
+}
+
+// PerformNirimgrAction performs the nirimgr action with its registered handler.
+func PerformNirimgrAction(a Action) error {
+	handler, ok := nirimgrHandlers[a.GetName()]
+	if !ok {
+		return fmt.Errorf("no handler for nirimgr action '%v'", a.GetName())
+	}
+	return handler(a)
+}
+
+// As returns the action as a pointer to T.
+//
+// The actions from the registries are pointers, but the actions created in code are usually values,
+// so the handlers accept both.
+func As[T any](a Action) (*T, error) {
+	switch action := any(a).(type) {
+	case *T:
+		return action, nil
+	case T:
+		return &action, nil
+	}
+	return nil, fmt.Errorf("unexpected action %T for '%v'", a, a.GetName())
+}
+
+// performExec runs the command of the Exec action.
+func performExec(a Action) error {
+	action, err := As[Exec](a)
+	if err != nil {
+		return err
+	}
+	if len(action.Command) == 0 {
+		return fmt.Errorf("no command given")
+	}
+	ids := map[string]string{}
+	for name, id := range map[string]uint64{
+		"NIRIMGR_ID":           action.ID,
+		"NIRIMGR_WINDOW_ID":    action.WindowID,
+		"NIRIMGR_WORKSPACE_ID": action.WorkspaceID,
+	} {
+		if id != 0 {
+			ids[name] = strconv.FormatUint(id, 10)
+		}
+	}
+	env := maps.Clone(ids)
+	for name, value := range action.Env {
+		env[name] = os.Expand(value, func(key string) string {
+			if id, ok := ids[key]; ok {
+				return id
+			}
+			return os.Getenv(key)
+		})
+	}
+	slog.Debug("Running command", "cmd", action.Command, "dir", action.Dir)
+	return common.StartDetached(action.Command, action.Dir, env)
+}
+
+// performNotify sends the notification of the Notify action.
+func performNotify(a Action) error {
+	action, err := As[Notify](a)
+	if err != nil {
+		return err
+	}
+	urgency := action.Urgency
+	if urgency == "" {
+		urgency = "normal"
+	}
+	command := []string{"notify-send", "--app-name", "nirimgr", "--urgency", urgency, "--", action.Summary}
+	if action.Body != "" {
+		command = append(command, action.Body)
+	}
+	return common.StartDetached(command, "", nil)
+}
+
+// performSleep sleeps for the duration of the Sleep action.
+func performSleep(a Action) error {
+	action, err := As[Sleep](a)
+	if err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(action.Duration)
+	if err != nil {
+		return fmt.Errorf("invalid duration '%v': %w", action.Duration, err)
+	}
+	time.Sleep(duration)
+	return nil
+}
+
+// performLog logs the message of the Log action.
+func performLog(a Action) error {
+	action, err := As[Log](a)
+	if err != nil {
+		return err
+	}
+	var level slog.Level
+	if action.Level != "" {
+		if err := level.UnmarshalText([]byte(action.Level)); err != nil {
+			return fmt.Errorf("invalid log level '%v'", action.Level)
+		}
+	}
+	var attrs []any
+	if action.ID != 0 {
+		attrs = append(attrs, "id", action.ID)
+	}
+	slog.Log(context.Background(), level, action.Message, attrs...)
+	return nil
+}
@@ -0,0 +1,62 @@
+package actions
+
+import (
+	"testing"
+
+	"github.com/soderluk/nirimgr/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNirimgrActionRegistryCreatesCorrectTypes(t *testing.T) {
+	for name, model := range NirimgrActionRegistry {
+		action := model()
+		assert.NotNil(t, action, name)
+		assert.Equal(t, name, action.GetName())
+		_, inNiri := ActionRegistry[name]
+		assert.False(t, inNiri, "nirimgr action %v shadows a niri action", name)
+		assert.True(t, IsNirimgrAction(name))
+	}
+	assert.False(t, IsNirimgrAction("FocusWindow"))
+}
+
+func TestFromRegistryNirimgrAction(t *testing.T) {
+	action := FromRegistry("FloatingPlace", []byte(`{"position": "center"}`))
+	assert.Equal(t, &FloatingPlace{AName: AName{Name: "FloatingPlace"}, Position: "center"}, action)
+
+	// The dynamic IDs are set on the nirimgr actions as well.
+	action = HandleDynamicIDs(action, models.PossibleKeys{ID: 5})
+	assert.Equal(t, uint64(5), action.(*FloatingPlace).ID)
+}
+
+func TestAs(t *testing.T) {
+	place, err := As[FloatingPlace](FloatingPlace{AName: AName{Name: "FloatingPlace"}, ID: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), place.ID)
+
+	place, err = As[FloatingPlace](&FloatingPlace{AName: AName{Name: "FloatingPlace"}, ID: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), place.ID)
+
+	_, err = As[FloatingPlace](Sleep{AName: AName{Name: "Sleep"}})
+	assert.Error(t, err)
+}
+
+func TestPerformNirimgrAction(t *testing.T) {
+	var performed *ScratchpadShow
+	RegisterNirimgrHandler("ScratchpadShow", func(a Action) error {
+		var err error
+		performed, err = As[ScratchpadShow](a)
+		return err
+	})
+	defer delete(nirimgrHandlers, "ScratchpadShow")
+
+	assert.NoError(t, PerformNirimgrAction(ScratchpadShow{AName: AName{Name: "ScratchpadShow"}, Cycle: true}))
+	assert.True(t, performed.Cycle)
+
+	assert.Error(t, PerformNirimgrAction(ScratchpadMove{AName: AName{Name: "ScratchpadMove"}}), "no handler registered")
+	assert.NoError(t, PerformNirimgrAction(Sleep{AName: AName{Name: "Sleep"}, Duration: "1ms"}))
+	assert.Error(t, PerformNirimgrAction(Sleep{AName: AName{Name: "Sleep"}, Duration: "soon"}))
+	assert.NoError(t, PerformNirimgrAction(Log{AName: AName{Name: "Log"}, Message: "hello", Level: "debug"}))
+	assert.Error(t, PerformNirimgrAction(Log{AName: AName{Name: "Log"}, Message: "hello", Level: "loud"}))
+	assert.Error(t, PerformNirimgrAction(Exec{AName: AName{Name: "Exec"}}), "no command")
+}
@@ -1,6 +1,12 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
 	"github.com/soderluk/nirimgr/events"
 
 	"github.com/spf13/cobra"
@@ -11,12 +17,143 @@ var eventsCmd = &cobra.Command{
 	Use:   "events",
 	Short: "Listen to niri event stream and act to events.",
 	Long: `This command listens to the niri event stream, and when an event is seen,
-		acts on it as defined in the configuration. See config.json rules section.`,
+		acts on it as defined in the configuration. See config.json rules section.
+		With --trace, explains how the rules matched every window and workspace on stderr.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if trace {
+			events.Trace = os.Stderr
+		}
 		events.Run()
 	},
 }
 
+// trace explains the rule matching in the events daemon, see the --trace flag.
+var trace bool
+
+// recordCmd records the niri event stream to a file.
+var recordCmd = &cobra.Command{
+	Use:   "record <file>",
+	Short: "Record the niri event stream to a file.",
+	Long: `Records the niri event stream to the file as JSON lines, with the time each event was received,
+until interrupted with Ctrl-C. The recording can be replayed with "nirimgr events replay".`,
+	SilenceUsage: true, // If there's an error during running the command, don't show usage.
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := os.Create(args[0])
+		if err != nil {
+			slog.Error("Could not create the recording", "file", args[0], "error", err.Error())
+			return errors.New("could not create the recording")
+		}
+		defer func() { _ = file.Close() }()
+
+		if err := events.Record(file); err != nil {
+			slog.Error("Could not record the events", "error", err.Error())
+			return errors.New("could not record the events")
+		}
+		return nil
+	},
+}
+
+// speed is the speed of the replay, see the --speed flag.
+var speed float64
+
+// replayCmd replays a recorded event stream.
+var replayCmd = &cobra.Command{
+	Use:   "replay <file>",
+	Short: "Replay a recorded event stream.",
+	Long: `Replays the events recorded with "nirimgr events record" through the rules and the configured events,
+like the events command does with the live event stream.
+
+The events are replayed with the recorded intervals divided by --speed, or without any intervals with --speed 0.
+With the global --dry-run flag, the actions are printed instead of performed. The replay ends when the steps
+started by the events are done.`,
+	SilenceUsage: true, // If there's an error during running the command, don't show usage.
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if speed < 0 {
+			return errors.New("invalid speed provided")
+		}
+		file, err := os.Open(args[0])
+		if err != nil {
+			slog.Error("Could not open the recording", "file", args[0], "error", err.Error())
+			return errors.New("could not open the recording")
+		}
+		defer func() { _ = file.Close() }()
+
+		recorded, err := events.ReadRecording(file)
+		if err != nil {
+			slog.Error("Could not read the recording", "file", args[0], "error", err.Error())
+			return errors.New("could not read the recording")
+		}
+		events.Handle(events.Replay(recorded, speed))
+		return nil
+	},
+}
+
+// The filters and the output format of the tail command, see its flags.
+var (
+	tailNames     []string
+	tailWindow    string
+	tailWorkspace string
+	tailWhen      string
+	tailOutput    string
+)
+
+// tailCmd shows the niri events as they arrive.
+var tailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Show the niri events as they arrive.",
+	Long: `Shows the niri events, and the events nirimgr synthesizes from them, as they arrive, until interrupted with Ctrl-C.
+Nothing is performed on the events, so this can be run alongside the events command.
+
+The window and workspace IDs in the events are resolved to the windows and workspaces, which are shown with the event.
+Filter the events by name with --name, by the window or workspace they refer to with the --window and --workspace
+selectors, which are "focused", an ID or a condition, e.g. --window "model.AppID == 'firefox'", or by the event itself
+with the --when condition. The focused window or workspace is the one focused when the tail starts.
+Use --output json to print the events as JSON lines, e.g. for jq.`,
+	SilenceUsage: true, // If there's an error during running the command, don't show usage.
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tailOutput != "text" && tailOutput != "json" {
+			return errors.New("invalid output provided")
+		}
+		filter := events.TailFilter{Names: tailNames, Window: tailWindow, Workspace: tailWorkspace, When: tailWhen}
+		if err := filter.Validate(); err != nil {
+			slog.Error("Invalid filter", "error", err.Error())
+			return errors.New("invalid filter provided")
+		}
+		filter, err := filter.ResolveFocused()
+		if err != nil {
+			slog.Error("Could not find the focused window or workspace", "error", err.Error())
+			return errors.New("could not find the focused window or workspace")
+		}
+		stream, err := events.EventStream()
+		if err != nil {
+			slog.Error("Could not get events", "error", err.Error())
+			return errors.New("could not get events")
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		events.Tail(stream, filter, func(tailed events.TailedEvent) {
+			if tailOutput == "json" {
+				if err := encoder.Encode(tailed); err != nil {
+					slog.Error("Could not print the event", "name", tailed.Name, "error", err.Error())
+				}
+				return
+			}
+			fmt.Println(tailed)
+		})
+		return nil
+	},
+}
+
 func init() {
+	eventsCmd.Flags().BoolVar(&trace, "trace", false, "explain how the rules matched every window and workspace")
+	tailCmd.Flags().StringSliceVar(&tailNames, "name", nil, "show only the events with the names, e.g. WindowFocusChanged")
+	tailCmd.Flags().StringVar(&tailWindow, "window", "", "show only the events of the window: focused, an ID or a condition")
+	tailCmd.Flags().StringVar(&tailWorkspace, "workspace", "", "show only the events of the workspace: focused, an ID or a condition")
+	tailCmd.Flags().StringVar(&tailWhen, "when", "", "show only the events matching the condition")
+	tailCmd.Flags().StringVarP(&tailOutput, "output", "o", "text", "the output format, text or json")
+	replayCmd.Flags().Float64Var(&speed, "speed", 1, "the speed of the replay, e.g. 2 for twice as fast as recorded")
+	eventsCmd.AddCommand(recordCmd, replayCmd, tailCmd)
 	RootCmd.AddCommand(eventsCmd)
 }
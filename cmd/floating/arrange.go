@@ -0,0 +1,79 @@
+package floating
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/internal/common"
+	"github.com/soderluk/nirimgr/internal/connection"
+	"github.com/soderluk/nirimgr/internal/placement"
+	"github.com/soderluk/nirimgr/models"
+	"github.com/spf13/cobra"
+)
+
+// arrangeCmd arranges all the floating windows on the focused workspace.
+var arrangeCmd = &cobra.Command{
+	Use:   "arrange <cascade|tile|stack>",
+	Short: "Arranges all the floating windows on the focused workspace.",
+	Long: `Arranges all the floating windows on the focused workspace in the working area of its output.
+
+cascade keeps the window sizes and offsets the windows diagonally from the top-left corner,
+tile splits the working area into a grid with a cell for each window,
+and stack splits the working area into rows with a row for each window.
+
+The windows are arranged in the order they were opened. Arrangements: ` + strings.Join(placement.Arrangements, ", "),
+	SilenceUsage: true, // If there's an error during running the command, don't show usage.
+	Args:         cobra.ExactArgs(1),
+	ValidArgs:    placement.Arrangements,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		workspaces, err := connection.ListWorkspaces()
+		if err != nil {
+			return errors.New("could not get workspaces")
+		}
+		workspace, err := common.FilterWorkspacesChain(workspaces, func(w *models.Workspace) bool {
+			return w.IsFocused
+		}).First()
+		if err != nil {
+			return errors.New("could not get the focused workspace")
+		}
+		output, err := getOutput(workspace.Output)
+		if err != nil {
+			return err
+		}
+
+		windows, err := connection.ListWindows()
+		if err != nil {
+			return errors.New("could not get windows")
+		}
+		floating := common.FilterWindowsChain(windows, func(w *models.Window) bool {
+			return w.WorkspaceID == workspace.ID && w.IsFloating
+		}).SortByID().Windows
+		if len(floating) == 0 {
+			slog.Debug("No floating windows on the focused workspace", "workspace", workspace.ID)
+			return nil
+		}
+
+		tiles := make([]placement.Rect, 0, len(floating))
+		for _, window := range floating {
+			tile, err := placement.Tile(window.Layout)
+			if err != nil {
+				return err
+			}
+			tiles = append(tiles, tile)
+		}
+		targets, err := placement.Arrange(args[0], workingArea(output), tiles, config.Config.Placement.Gaps)
+		if err != nil {
+			return err
+		}
+		for i, window := range floating {
+			placeWindow(window, output, tiles[i], targets[i])
+		}
+		return nil
+	},
+}
+
+func init() {
+	floatingCmd.AddCommand(arrangeCmd)
+}
@@ -0,0 +1,57 @@
+package floating
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/internal/placement"
+	"github.com/spf13/cobra"
+)
+
+// gridCmd snaps a floating window into a grid cell.
+var gridCmd = &cobra.Command{
+	Use:   "grid <<cols>x<rows>|layout> <cell>",
+	Short: "Snaps a floating window into a grid cell.",
+	Long: `Sizes and positions the focused floating window into a cell of a grid on its output.
+
+The grid is either given as <cols>x<rows>, e.g. "3x2", or the name of a layout configured in the placement layouts.
+The cell is either the 1-based index of the cell counting row by row from the top-left, <col>,<row> optionally followed
+by the number of columns and rows to span, e.g. "1,1,2,2", or the name of a cell configured in the layout.`,
+	SilenceUsage: true, // If there's an error during running the command, don't show usage.
+	Args:         cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		grid, cellSpec := args[0], args[1]
+		if layout, ok := config.Config.Placement.Layouts[grid]; ok {
+			grid = layout.Grid
+			if named, ok := layout.Cells[cellSpec]; ok {
+				cellSpec = named
+			}
+		} else if !strings.Contains(grid, "x") {
+			return fmt.Errorf("no layout named '%v'", grid)
+		}
+		cols, rows, err := placement.ParseGrid(grid)
+		if err != nil {
+			return err
+		}
+		cell, err := placement.ParseCell(cellSpec, cols, rows)
+		if err != nil {
+			return err
+		}
+
+		window, output, area, err := focusedFloatingWindow()
+		if err != nil {
+			return err
+		}
+		tile, err := placement.Tile(window.Layout)
+		if err != nil {
+			return err
+		}
+		placeWindow(window, output, tile, placement.Cell(area, config.Config.Placement.Gaps, cols, rows, cell))
+		return nil
+	},
+}
+
+func init() {
+	floatingCmd.AddCommand(gridCmd)
+}
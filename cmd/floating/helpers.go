@@ -0,0 +1,120 @@
+package floating
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/soderluk/nirimgr/actions"
+	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/internal/common"
+	"github.com/soderluk/nirimgr/internal/connection"
+	"github.com/soderluk/nirimgr/internal/placement"
+	"github.com/soderluk/nirimgr/models"
+)
+
+// focusedFloatingWindow returns the focused floating window, its output, and the working area of the output.
+func focusedFloatingWindow() (*models.Window, *models.Output, placement.Rect, error) {
+	return floatingWindow(0)
+}
+
+// floatingWindow returns the floating window with the given ID, its output, and the working area of the output.
+//
+// If the ID is 0, the focused floating window is returned.
+func floatingWindow(id uint64) (*models.Window, *models.Output, placement.Rect, error) {
+	windows, err := connection.ListWindows()
+	if err != nil {
+		return nil, nil, placement.Rect{}, errors.New("could not get windows")
+	}
+	window, err := common.FilterWindowsChain(windows, func(w *models.Window) bool {
+		if id != 0 {
+			return w.ID == id && w.IsFloating
+		}
+		return w.IsFocused && w.IsFloating
+	}).First()
+	if err != nil {
+		if id != 0 {
+			return nil, nil, placement.Rect{}, errors.New("no floating window with the given ID")
+		}
+		return nil, nil, placement.Rect{}, errors.New("no active floating window")
+	}
+
+	workspaces, err := connection.ListWorkspaces()
+	if err != nil {
+		return nil, nil, placement.Rect{}, errors.New("could not get workspaces")
+	}
+	workspace, err := common.FilterWorkspacesChain(workspaces, func(w *models.Workspace) bool {
+		return w.ID == window.WorkspaceID
+	}).First()
+	if err != nil {
+		return nil, nil, placement.Rect{}, errors.New("could not get the workspace of the window")
+	}
+	output, err := getOutput(workspace.Output)
+	if err != nil {
+		return nil, nil, placement.Rect{}, err
+	}
+	return window, output, workingArea(output), nil
+}
+
+// getOutput returns the output with the given name.
+func getOutput(name string) (*models.Output, error) {
+	outputs, err := connection.ListOutputs()
+	if err != nil {
+		return nil, errors.New("could not get outputs")
+	}
+	output, err := common.FilterOutputsChain(outputs, func(o *models.Output) bool {
+		return o.Name == name
+	}).First()
+	if err != nil {
+		return nil, errors.New("could not get output")
+	}
+	return output, nil
+}
+
+// workingArea returns the working area of the output, i.e. the output without the configured exclusive zones.
+//
+// The working area is in the output's own coordinates, which is what niri uses for the window layout.
+func workingArea(output *models.Output) placement.Rect {
+	var zones []models.Insets
+	if len(config.Config.Placement.ExclusiveZones) > 0 {
+		layers, err := connection.ListLayers()
+		if err != nil {
+			// Older niri versions might not support the request, so place the window on the whole output.
+			slog.Warn("Could not get layers, ignoring the exclusive zones", "error", err.Error())
+		}
+		zones = placement.ExclusiveZones(layers, output.Name, config.Config.Placement.ExclusiveZones)
+	}
+	return placement.WorkingArea(output.Logical, zones)
+}
+
+// placeWindow resizes and moves the window's tile to the target rectangle.
+//
+// The window is moved relative to its current position, so we don't need to know the origin niri uses
+// for the floating window positions. The target is snapped to the output's physical pixels.
+func placeWindow(window *models.Window, output *models.Output, tile, target placement.Rect) {
+	target = placement.Snap(target, output.Logical.Scale)
+	var actionList []actions.Action
+	if target.Width != tile.Width || target.Height != tile.Height {
+		width, height := placement.WindowSize(window.Layout, target.Width, target.Height)
+		actionList = append(actionList,
+			actions.SetWindowWidth{
+				AName:  actions.AName{Name: "SetWindowWidth"},
+				ID:     window.ID,
+				Change: actions.SizeChange{SetFixed: width},
+			},
+			actions.SetWindowHeight{
+				AName:  actions.AName{Name: "SetWindowHeight"},
+				ID:     window.ID,
+				Change: actions.SizeChange{SetFixed: height},
+			},
+		)
+	}
+	actionList = append(actionList, actions.MoveFloatingWindow{
+		AName: actions.AName{Name: "MoveFloatingWindow"},
+		ID:    window.ID,
+		X:     actions.PositionChange{AdjustFixed: target.X - tile.X},
+		Y:     actions.PositionChange{AdjustFixed: target.Y - tile.Y},
+	})
+	for _, action := range actionList {
+		connection.PerformAction(action)
+	}
+}
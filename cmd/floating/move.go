@@ -1,112 +1,172 @@
 package floating
 
 import (
-	"encoding/json"
 	"errors"
+	"log/slog"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/soderluk/nirimgr/actions"
+	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/events"
 	"github.com/soderluk/nirimgr/internal/common"
 	"github.com/soderluk/nirimgr/internal/connection"
+	"github.com/soderluk/nirimgr/internal/placement"
 	"github.com/soderluk/nirimgr/models"
 	"github.com/spf13/cobra"
 )
 
+// outputMoveTimeout is how long to wait for niri to move the window to the other output.
+const outputMoveTimeout = time.Second
+
+// toOutput is the output to move the window to, see the --to-output flag.
+var toOutput string
+
 // moveCmd moves a floating window to the left/right/top/bottom edges.
 //
 // This is from https://github.com/YaLTeR/niri/discussions/1656#discussioncomment-14268880
 var moveCmd = &cobra.Command{
-	Use:          "move",
-	Short:        "Moves a floating window to the left/right/top/bottom edges of the screen.",
+	Use:   "move <left|right|up|down> [border]",
+	Short: "Moves a floating window to the left/right/top/bottom edges of the screen.",
+	Long: `Moves the focused floating window to the edge of the working area of its output in the given direction.
+
+The border is the gap left between the window and the edge, defaulting to the configured placement gaps,
+or 1 if the gaps aren't configured.
+
+With --to-output, the window is moved to the active workspace of another output instead, either the adjacent output
+in the given direction (left, right, up or down) or the output with the given name. The window keeps its position
+and size relative to the output.`,
 	SilenceUsage: true, // If there's an error during running the command, don't show usage.
-	Args:         cobra.MinimumNArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if toOutput != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.RangeArgs(1, 2)(cmd, args)
+	},
+	ValidArgs: placement.Directions,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if toOutput != "" {
+			return moveToOutput(toOutput)
+		}
+
 		direction := args[0]
-		// Note: The border must be at minimum 1, because if it's 0, the "newX" will be empty.
-		border := float64(1)
+		border := config.Config.Placement.Gaps
+		if border == 0 {
+			// Keep the previous default border when the gaps aren't configured.
+			border = 1
+		}
 		if len(args) > 1 {
-			border, _ = strconv.ParseFloat(args[1], 64)
+			var err error
+			border, err = strconv.ParseFloat(args[1], 64)
+			if err != nil {
+				return errors.New("invalid border provided")
+			}
 		}
 
-		windows, err := connection.ListWindows()
+		window, output, area, err := focusedFloatingWindow()
 		if err != nil {
-			return errors.New("could not get windows")
+			return err
 		}
-		window, err := common.FilterWindowsChain(windows, func(w *models.Window) bool {
-			return w.IsFocused && w.IsFloating
-		}).First()
+		tile, err := placement.Tile(window.Layout)
 		if err != nil {
-			return errors.New("no active floating window")
+			return err
 		}
-
-		workspaces, err := connection.ListWorkspaces()
+		target, err := placement.Move(direction, area, tile, border)
 		if err != nil {
-			return errors.New("could not get workspaces")
+			return errors.New("invalid direction provided")
 		}
+		placeWindow(window, output, tile, target)
+		return nil
+	},
+}
 
-		workspace, err := common.FilterWorkspacesChain(workspaces, func(w *models.Workspace) bool {
-			return w.IsFocused
-		}).First()
-		if err != nil {
-			return errors.New("could not get focused workspace")
-		}
-		outputName := workspace.Output
-		outputs, err := connection.ListOutputs()
+// moveToOutput moves the focused floating window to the active workspace of the target output.
+//
+// The target is either a direction to the adjacent output, or the name of the output.
+func moveToOutput(target string) error {
+	window, output, area, err := focusedFloatingWindow()
+	if err != nil {
+		return err
+	}
+	tile, err := placement.Tile(window.Layout)
+	if err != nil {
+		return err
+	}
+
+	outputs, err := connection.ListOutputs()
+	if err != nil {
+		return errors.New("could not get outputs")
+	}
+	var targetOutput *models.Output
+	if slices.Contains(placement.Directions, target) {
+		targetOutput, err = placement.AdjacentOutput(outputs, output, target)
 		if err != nil {
-			return errors.New("could not get outputs")
+			slog.Error("Could not find the output", "error", err.Error())
+			return err
 		}
-		output, err := common.FilterOutputsChain(outputs, func(o *models.Output) bool {
-			return o.Name == outputName
+	} else {
+		targetOutput, err = common.FilterOutputsChain(outputs, func(o *models.Output) bool {
+			return o.Name == target
 		}).First()
 		if err != nil {
-			return errors.New("could not get output")
+			return errors.New("no output named " + target)
 		}
+	}
+	if targetOutput.Name == output.Name {
+		slog.Debug("Window is already on the output", "output", output.Name)
+		return nil
+	}
 
-		width := float64(window.Layout.WindowSize[0])
-		height := float64(window.Layout.WindowSize[1])
-		x := float64(window.Layout.TilePosInWorkspaceView[0])
-		y := float64(window.Layout.TilePosInWorkspaceView[1])
-
-		screenWidth := float64(output.Logical.Width)
-		screenHeight := float64(output.Logical.Height)
-
-		var newX, newY float64
-		switch direction {
-		case "left":
-			newX = border
-			newY = y - 34
-		case "right":
-			newX = (screenWidth - width - border)
-			newY = y - 34
-		case "up":
-			newX = x
-			newY = border
-		case "down":
-			newX = x
-			newY = (screenHeight - height - border) - 34
-		default:
-			return errors.New("invalid direction provided")
-		}
+	workspaces, err := connection.ListWorkspaces()
+	if err != nil {
+		return errors.New("could not get workspaces")
+	}
+	workspace, err := common.FilterWorkspacesChain(workspaces, func(w *models.Workspace) bool {
+		return w.Output == targetOutput.Name && w.IsActive
+	}).First()
+	if err != nil {
+		return errors.New("could not get the active workspace of " + targetOutput.Name)
+	}
 
-		data := map[string]any{
-			"x": map[string]float64{
-				"SetFixed": newX,
-			},
-			"y": map[string]float64{
-				"SetFixed": newY,
-			},
-		}
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			return errors.New("error parsing action json data")
-		}
-		action := actions.FromRegistry("MoveFloatingWindow", jsonData)
-		action = actions.HandleDynamicIDs(action, models.PossibleKeys{ID: window.ID})
-		connection.PerformAction(action)
+	move := actions.MoveWindowToWorkspace{
+		AName:     actions.AName{Name: "MoveWindowToWorkspace"},
+		WindowID:  window.ID,
+		Reference: actions.WorkspaceReferenceArg{ID: workspace.ID},
+		Focus:     true,
+	}
+	transferred := placement.Transfer(tile, area, workingArea(targetOutput))
+	// In a dry-run the window is never moved, so there's nothing to wait for. The placement is relative to the current tile.
+	if connection.DryRun != nil {
+		connection.PerformAction(move)
+		placeWindow(window, targetOutput, tile, transferred)
 		return nil
-	},
+	}
+
+	// Listen to the events before moving, so we get the window's layout on the new output.
+	stream, err := events.EventStream()
+	if err != nil {
+		slog.Error("Could not get events", "error", err.Error())
+		return errors.New("could not get events")
+	}
+	connection.PerformAction(move)
+	moved, err := events.WaitForWindow(stream, outputMoveTimeout, func(w *models.Window) bool {
+		return w.ID == window.ID && w.WorkspaceID == workspace.ID
+	})
+	if err != nil {
+		slog.Error("Window wasn't moved to the output", "output", targetOutput.Name, "error", err.Error())
+		return err
+	}
+	movedTile, err := placement.Tile(moved.Layout)
+	if err != nil {
+		return err
+	}
+
+	placeWindow(moved, targetOutput, movedTile, transferred)
+	return nil
 }
 
 func init() {
+	moveCmd.Flags().StringVar(&toOutput, "to-output", "", "move the window to the output in the direction (left|right|up|down) or with the name")
 	floatingCmd.AddCommand(moveCmd)
 }
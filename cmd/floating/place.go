@@ -0,0 +1,59 @@
+package floating
+
+import (
+	"strings"
+
+	"github.com/soderluk/nirimgr/actions"
+	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/internal/placement"
+	"github.com/spf13/cobra"
+)
+
+// placeCmd places a floating window at a position on the screen.
+var placeCmd = &cobra.Command{
+	Use:   "place <position>",
+	Short: "Places a floating window at a position on the screen.",
+	Long: `Places the focused floating window at the given position in the working area of its output.
+
+The corners, edges and center keep the size of the window, the halves and thirds resize the window to fill that part.
+The configured placement gaps are kept around the window, and the configured exclusive zones (e.g. bars) are left free.
+
+Positions: ` + strings.Join(placement.Positions, ", "),
+	SilenceUsage: true, // If there's an error during running the command, don't show usage.
+	Args:         cobra.ExactArgs(1),
+	ValidArgs:    placement.Positions,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return placeFloatingWindow(0, args[0])
+	},
+}
+
+func init() {
+	floatingCmd.AddCommand(placeCmd)
+	actions.RegisterNirimgrHandler("FloatingPlace", func(a actions.Action) error {
+		action, err := actions.As[actions.FloatingPlace](a)
+		if err != nil {
+			return err
+		}
+		return placeFloatingWindow(action.ID, action.Position)
+	})
+}
+
+// placeFloatingWindow places the floating window with the given ID at the position.
+//
+// If the ID is 0, the focused floating window is placed.
+func placeFloatingWindow(id uint64, position string) error {
+	window, output, area, err := floatingWindow(id)
+	if err != nil {
+		return err
+	}
+	tile, err := placement.Tile(window.Layout)
+	if err != nil {
+		return err
+	}
+	target, err := placement.Place(position, area, tile, config.Config.Placement.Gaps)
+	if err != nil {
+		return err
+	}
+	placeWindow(window, output, tile, target)
+	return nil
+}
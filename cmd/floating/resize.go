@@ -0,0 +1,40 @@
+package floating
+
+import (
+	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/internal/placement"
+	"github.com/spf13/cobra"
+)
+
+// resizeCmd resizes a floating window.
+var resizeCmd = &cobra.Command{
+	Use:   "resize <width>[x<height>]",
+	Short: "Resizes a floating window.",
+	Long: `Resizes the focused floating window, keeping it centered on its current position.
+
+The width and height are either "+N" or "-N" to grow or shrink by N pixels, "N%" for a fraction of the
+working area of the output, or "N" for a fixed size in pixels. Without the height, the width is used for both.
+E.g. "+50" grows the window by 50 pixels, and "60%x80%" resizes it to 60% of the width and 80% of the height.`,
+	SilenceUsage: true, // If there's an error during running the command, don't show usage.
+	Args:         cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		window, output, area, err := focusedFloatingWindow()
+		if err != nil {
+			return err
+		}
+		tile, err := placement.Tile(window.Layout)
+		if err != nil {
+			return err
+		}
+		target, err := placement.Resize(args[0], area, tile, config.Config.Placement.Gaps)
+		if err != nil {
+			return err
+		}
+		placeWindow(window, output, tile, target)
+		return nil
+	},
+}
+
+func init() {
+	floatingCmd.AddCommand(resizeCmd)
+}
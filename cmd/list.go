@@ -66,6 +66,25 @@ func listActions() {
 	if err != nil {
 		fmt.Printf("could not render table %v", err)
 	}
+
+	fmt.Println("nirimgr performs the following actions itself:")
+	nirimgrActionsSorted := msort(actions.NirimgrActionRegistry)
+
+	table = startTable()
+	for _, name := range nirimgrActionsSorted {
+		action := actions.NirimgrActionRegistry[name]
+		model := action()
+		fields := extractFields(model)
+		err := table.Append([]string{name, fmt.Sprintf("%+v", fields)})
+		if err != nil {
+			fmt.Printf("could not append %v to table, error: %v", name, err)
+			continue
+		}
+	}
+	err = table.Render()
+	if err != nil {
+		fmt.Printf("could not render table %v", err)
+	}
 }
 
 // listEvents lists all the defined events.
@@ -88,6 +107,25 @@ func listEvents() {
 	if err != nil {
 		fmt.Printf("could not render table %v", err)
 	}
+
+	fmt.Println("nirimgr synthesizes the following events from the state changes:")
+	synthesizedSorted := msort(events.SynthesizedEventRegistry)
+
+	table = startTable()
+	for _, name := range synthesizedSorted {
+		event := events.SynthesizedEventRegistry[name]
+		model := event()
+		fields := extractFields(model)
+		err := table.Append([]string{name, fmt.Sprintf("%+v", fields)})
+		if err != nil {
+			fmt.Printf("could not append %v to table, error: %v", name, err)
+			continue
+		}
+	}
+	err = table.Render()
+	if err != nil {
+		fmt.Printf("could not render table %v", err)
+	}
 }
 
 // msort sorts the given map by keys and returns the sorted list as a slice.
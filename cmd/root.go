@@ -1,12 +1,24 @@
 // Package cmd contains all the commands nirimgr supports.
 //
 // The root command just specifies nirimgr cli-name. Use the sub-commands to use nirimgr.
+// With the global --dry-run flag, the actions are printed instead of performed, together with the
+// rule or event handler they came from.
 //
 // # Events
 //
 // The events command starts listening on the Niri event stream.
+// Use record to record the event stream to a file, and replay to replay the recorded events.
+// Use tail to show the events as they arrive.
 //
-//	Usage: nirimgr events
+//	Usage: nirimgr events [record <file>|replay <file> [--speed N]|tail [--name N] [--output json]]
+//
+// With --trace, the events command explains how the rules matched every window and workspace.
+//
+// # Rules
+//
+// The rules explain command explains why the rules did or didn't match a window or workspace.
+//
+//	Usage: nirimgr rules explain (--window <selector>|--workspace <selector>)
 //
 // # List
 //
@@ -21,7 +33,11 @@
 // Added in v0.3.0: spawn-or-focus [app-id]
 // Using the spawn-or-focus [app-id] will either spawn a specific app, or focus it if it's already open.
 //
-//	Usage: nirimgr scratch [move|show|spawn-or-focus [app-id]]
+// Using the toggle [name] will toggle the named scratchpad as configured in the config.json.
+// Without a name, toggle hides the visible scratchpad windows, or shows a window from the scratchpad.
+// Using hide will move the visible scratchpad windows back to the scratchpad workspace.
+//
+//	Usage: nirimgr scratch [move|show|hide|spawn-or-focus [app-id]|toggle [name]]
 package cmd
 
 import (
@@ -30,6 +46,8 @@ import (
 	"runtime/debug"
 
 	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/internal/connection"
+	"github.com/soderluk/nirimgr/internal/state"
 	"github.com/spf13/cobra"
 )
 
@@ -44,6 +62,19 @@ var RootCmd = &cobra.Command{
 		floating, when the app id and title of the window matches a rule.
 		There is also a "scratchpad" command that can be run on a key-bind.`,
 	Version: getVersionInfo(),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if dryRun {
+			connection.DryRun = os.Stdout
+			state.DryRun = true
+		}
+	},
+}
+
+// dryRun prints the actions instead of performing them, see the --dry-run flag.
+var dryRun bool
+
+func init() {
+	RootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print the actions instead of performing them")
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
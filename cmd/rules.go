@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/soderluk/nirimgr/events"
+
+	"github.com/spf13/cobra"
+)
+
+// rulesCmd is the main command for inspecting the configured rules.
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Inspect the configured rules. See --help for the sub-commands.",
+}
+
+// The window and workspace to explain the rules for, see the explain flags.
+var (
+	explainWindow    string
+	explainWorkspace string
+)
+
+// explainCmd explains why the rules did or didn't match a window or workspace.
+var explainCmd = &cobra.Command{
+	Use:   "explain (--window <selector>|--workspace <selector>)",
+	Short: "Explain why the rules did or didn't match a window or workspace.",
+	Long: `Evaluates every configured rule against the window or workspace, like the events command does, and
+shows per rule and per match which fields matched, which exclude hit, and the results of the action conditions.
+
+The selector is "focused", the ID of the window or workspace, or a condition, e.g. --window "model.AppID == 'firefox'".
+Use "nirimgr events --trace" to see the explanations in the events daemon as the windows and workspaces change.`,
+	SilenceUsage: true, // If there's an error during running the command, don't show usage.
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (explainWindow == "") == (explainWorkspace == "") {
+			return errors.New("provide either --window or --workspace")
+		}
+		if explainWindow != "" {
+			window, err := events.FindWindow(explainWindow)
+			if err != nil {
+				slog.Error("Could not find the window", "selector", explainWindow, "error", err.Error())
+				return errors.New("could not find the window")
+			}
+			fmt.Print(events.ExplainWindow(window, nil))
+			return nil
+		}
+		workspace, err := events.FindWorkspace(explainWorkspace)
+		if err != nil {
+			slog.Error("Could not find the workspace", "selector", explainWorkspace, "error", err.Error())
+			return errors.New("could not find the workspace")
+		}
+		fmt.Print(events.ExplainWorkspace(workspace, nil))
+		return nil
+	},
+}
+
+func init() {
+	explainCmd.Flags().StringVar(&explainWindow, "window", "", "the window to explain the rules for")
+	explainCmd.Flags().StringVar(&explainWorkspace, "workspace", "", "the workspace to explain the rules for")
+	rulesCmd.AddCommand(explainCmd)
+	RootCmd.AddCommand(rulesCmd)
+}
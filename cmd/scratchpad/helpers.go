@@ -2,18 +2,33 @@ package scratchpad
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 
+	"github.com/soderluk/nirimgr/actions"
 	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/events"
 	"github.com/soderluk/nirimgr/internal/connection"
+	"github.com/soderluk/nirimgr/internal/launcher"
+	"github.com/soderluk/nirimgr/internal/state"
 	"github.com/soderluk/nirimgr/models"
 )
 
+// listWorkspaces is a variable that points to connection.ListWorkspaces, allowing us to mock it in tests.
+var listWorkspaces = connection.ListWorkspaces
+
+// focusedOutput is a variable that points to getFocusedOutput, allowing us to mock it in tests.
+var focusedOutput = getFocusedOutput
+
+// performAction is a variable that points to connection.PerformAction, allowing us to mock it in tests.
+var performAction = connection.PerformAction
+
 // getWorkspace returns a workspace.
 //
 // Given the wtype, we return either the named workspace, focused or active workspace.
 func getWorkspace(wtype string) (*models.Workspace, error) {
-	workspaces, err := connection.ListWorkspaces()
+	workspaces, err := listWorkspaces()
 	if err != nil {
 		return nil, err
 	}
@@ -64,6 +79,163 @@ func getFocusedWindow() (*models.Window, error) {
 	return window, nil
 }
 
+// getFocusedOutput returns the currently focused output.
+func getFocusedOutput() (*models.Output, error) {
+	response, err := connection.PerformRequest(models.FocusedOutput)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := <-response
+
+	var output *models.Output
+	if err := json.Unmarshal(resp.Ok["FocusedOutput"], &output); err != nil {
+		return nil, err
+	}
+	if output == nil {
+		return nil, fmt.Errorf("no focused output")
+	}
+	return output, nil
+}
+
+// getShowWorkspace returns the workspace the scratchpad windows are shown on.
+//
+// This is the focused workspace, or the active workspace of the focused output if scratchpadShow.onFocusedOutput is set.
+func getShowWorkspace() (*models.Workspace, error) {
+	if !config.Config.ScratchpadShow.OnFocusedOutput {
+		return getWorkspace("focused")
+	}
+	output, err := focusedOutput()
+	if err != nil {
+		return nil, err
+	}
+	workspaces, err := listWorkspaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, workspace := range workspaces {
+		if workspace.Output == output.Name && workspace.IsActive {
+			return workspace, nil
+		}
+	}
+	return nil, fmt.Errorf("no active workspace on output '%v'", output.Name)
+}
+
+// centerWindow returns the actions to center the floating window on the output of the given workspace.
+//
+// The position is calculated from the output's logical size and the current size of the tile.
+func centerWindow(window *models.Window, workspace *models.Workspace) []actions.Action {
+	outputs, err := connection.ListOutputs()
+	if err != nil {
+		slog.Error("Could not list outputs", "error", err.Error())
+		return nil
+	}
+	var output *models.Output
+	for _, o := range outputs {
+		if o.Name == workspace.Output {
+			output = o
+			break
+		}
+	}
+	if output == nil {
+		slog.Warn("Could not find the output of the workspace, not centering", "output", workspace.Output)
+		return nil
+	}
+
+	var width, height float64
+	switch {
+	case len(window.Layout.TileSize) == 2:
+		width, height = window.Layout.TileSize[0], window.Layout.TileSize[1]
+	case len(window.Layout.WindowSize) == 2:
+		width, height = float64(window.Layout.WindowSize[0]), float64(window.Layout.WindowSize[1])
+	}
+	return []actions.Action{
+		actions.MoveFloatingWindow{
+			AName: actions.AName{Name: "MoveFloatingWindow"},
+			ID:    window.ID,
+			X:     actions.PositionChange{SetFixed: max(0, (float64(output.Logical.Width)-width)/2)},
+			Y:     actions.PositionChange{SetFixed: max(0, (float64(output.Logical.Height)-height)/2)},
+		},
+	}
+}
+
+// windowActions parses the configured raw actions, targeting them at the given window.
+//
+// The templates in the actions are rendered with the window as the model.
+func windowActions(window *models.Window, rawActions map[string]json.RawMessage) []actions.Action {
+	rendered := make(map[string]json.RawMessage, len(rawActions))
+	for name, params := range rawActions {
+		// The steps are performed after the actions, see performWindowSteps.
+		if name == models.StepsAction {
+			continue
+		}
+		params, err := events.RenderParams(params, window)
+		if err != nil {
+			slog.Error("Could not render action params", "name", name, "error", err.Error())
+			continue
+		}
+		rendered[name] = params
+	}
+	var actionList []actions.Action
+	for _, action := range actions.ParseRawActions(rendered) {
+		actionList = append(actionList, actions.HandleDynamicIDs(action, models.PossibleKeys{
+			ID:       window.ID,
+			WindowID: window.ID,
+		}))
+	}
+	return actionList
+}
+
+// performWindowSteps performs the "steps" of the configured raw actions on the given window.
+//
+// The steps are performed in order, and this returns when all of them are done.
+func performWindowSteps(window *models.Window, rawActions ...map[string]json.RawMessage) {
+	for _, raw := range rawActions {
+		rawSteps, ok := raw[models.StepsAction]
+		if !ok {
+			continue
+		}
+		var steps []models.Step
+		if err := json.Unmarshal(rawSteps, &steps); err != nil {
+			slog.Error("Could not parse steps", "error", err.Error())
+			continue
+		}
+		events.RunSteps("", steps, window, models.PossibleKeys{
+			ID:       window.ID,
+			WindowID: window.ID,
+		})
+	}
+}
+
+// loadState loads the state, and stops tracking the windows that are no longer open.
+//
+// Returns the state and the currently open windows. The state is only read, the changes are saved with updateState.
+func loadState() (*state.State, []*models.Window, error) {
+	s, err := state.Load()
+	if err != nil {
+		slog.Error("Could not load state", "error", err.Error())
+		return nil, nil, errors.New("could not load state")
+	}
+	windows, err := listWindows()
+	if err != nil {
+		slog.Error("Could not list windows", "error", err.Error())
+		return nil, nil, errors.New("could not list windows")
+	}
+	s.Prune(windows)
+	return s, windows, nil
+}
+
+// updateState applies the update to the saved state, logging any errors.
+//
+// The state is locked while it's updated, since both the events daemon and the commands update it. So no actions
+// may be performed in the update. Failing to save the state only means the windows aren't tracked, so it's not
+// fatal for the commands.
+func updateState(update func(*state.State)) {
+	if err := state.Update(update); err != nil {
+		slog.Error("Could not save state", "error", err.Error())
+	}
+}
+
 // filterWindows returns a slice of window models depending on the filtering function.
 func filterWindows(data []*models.Window, f func(*models.Window) bool) []*models.Window {
 	w := make([]*models.Window, 0)
@@ -75,3 +247,31 @@ func filterWindows(data []*models.Window, f func(*models.Window) bool) []*models
 
 	return w
 }
+
+// selectWindow opens the configured launcher to select one of the given windows.
+//
+// Returns launcher.ErrCancelled if the user dismissed the launcher.
+func selectWindow(windows []*models.Window) (*models.Window, error) {
+	l, err := launcher.Default()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]launcher.Entry, 0, len(windows))
+	for _, window := range windows {
+		label := window.Title
+		if label == "" {
+			label = window.AppID
+		}
+		entries = append(entries, launcher.Entry{ID: window.ID, Label: label})
+	}
+	selected, err := l.Select(entries)
+	if err != nil {
+		return nil, err
+	}
+	for _, window := range windows {
+		if window.ID == selected.ID {
+			return window, nil
+		}
+	}
+	return nil, fmt.Errorf("no window with id %d", selected.ID)
+}
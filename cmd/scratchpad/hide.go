@@ -0,0 +1,120 @@
+package scratchpad
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/soderluk/nirimgr/actions"
+	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/internal/state"
+	"github.com/soderluk/nirimgr/models"
+	"github.com/spf13/cobra"
+)
+
+var hideCmd = &cobra.Command{
+	Use:          "hide",
+	Short:        "Hide the shown scratchpad windows",
+	Long:         `Moves the visible windows that came from the scratchpad back to the scratchpad workspace, restoring their original floating size and position.`,
+	SilenceUsage: true, // If there's an error during running the command, don't show usage.
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return hideScratchpad()
+	},
+}
+
+func init() {
+	ScratchCmd.AddCommand(hideCmd)
+}
+
+// hideScratchpad moves the visible scratchpad windows back to the scratchpad workspace.
+func hideScratchpad() error {
+	s, windows, err := loadState()
+	if err != nil {
+		return err
+	}
+	visible, err := visibleScratchpadWindows(s, windows)
+	if err != nil {
+		return err
+	}
+	if len(visible) == 0 {
+		slog.Debug("No visible scratchpad windows to hide")
+		return nil
+	}
+	scratchpad, err := getWorkspace(config.Config.ScratchpadWorkspace)
+	if err != nil {
+		return err
+	}
+	for _, window := range visible {
+		tracked, _ := s.ScratchpadWindow(window.ID)
+		restoreGeometry(window, tracked)
+		hideWindow(window, scratchpad)
+	}
+	updateState(func(s *state.State) { s.Prune(windows) })
+	return nil
+}
+
+// visibleScratchpadWindows returns the tracked scratchpad windows that are on an active workspace.
+//
+// The windows on the scratchpad workspace itself are never considered visible.
+func visibleScratchpadWindows(s *state.State, windows []*models.Window) ([]*models.Window, error) {
+	workspaces, err := listWorkspaces()
+	if err != nil {
+		slog.Error("Could not list workspaces", "error", err.Error())
+		return nil, errors.New("could not list workspaces")
+	}
+	scratchpadName := config.Config.ScratchpadWorkspace
+	if scratchpadName == "" {
+		scratchpadName = "scratchpad"
+	}
+	active := make(map[uint64]struct{})
+	for _, workspace := range workspaces {
+		if workspace.IsActive && workspace.Name != scratchpadName {
+			active[workspace.ID] = struct{}{}
+		}
+	}
+	return filterWindows(windows, func(w *models.Window) bool {
+		if _, ok := s.ScratchpadWindow(w.ID); !ok {
+			return false
+		}
+		_, ok := active[w.WorkspaceID]
+		return ok
+	}), nil
+}
+
+// restoreGeometry restores the original floating size and position of the window.
+//
+// The position is restored relative to the current position, since both are in workspace view coordinates.
+func restoreGeometry(window *models.Window, tracked state.ScratchpadWindow) {
+	if !tracked.WasFloating || !window.IsFloating {
+		return
+	}
+	actionList := []actions.Action{}
+	if tracked.Width > 0 && tracked.Height > 0 {
+		actionList = append(actionList,
+			actions.SetWindowWidth{
+				AName:  actions.AName{Name: "SetWindowWidth"},
+				ID:     window.ID,
+				Change: actions.SizeChange{SetFixed: tracked.Width},
+			},
+			actions.SetWindowHeight{
+				AName:  actions.AName{Name: "SetWindowHeight"},
+				ID:     window.ID,
+				Change: actions.SizeChange{SetFixed: tracked.Height},
+			},
+		)
+	}
+	if len(window.Layout.TilePosInWorkspaceView) == 2 {
+		dx := tracked.X - window.Layout.TilePosInWorkspaceView[0]
+		dy := tracked.Y - window.Layout.TilePosInWorkspaceView[1]
+		if dx != 0 || dy != 0 {
+			actionList = append(actionList, actions.MoveFloatingWindow{
+				AName: actions.AName{Name: "MoveFloatingWindow"},
+				ID:    window.ID,
+				X:     actions.PositionChange{AdjustFixed: dx},
+				Y:     actions.PositionChange{AdjustFixed: dy},
+			})
+		}
+	}
+	for _, action := range actionList {
+		performAction(action)
+	}
+}
@@ -1,9 +1,15 @@
 package scratchpad
 
 import (
+	"errors"
+	"log/slog"
+
 	"github.com/soderluk/nirimgr/actions"
 	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/internal/common"
 	"github.com/soderluk/nirimgr/internal/connection"
+	"github.com/soderluk/nirimgr/internal/state"
+	"github.com/soderluk/nirimgr/models"
 	"github.com/spf13/cobra"
 )
 
@@ -18,6 +24,53 @@ var moveCmd = &cobra.Command{
 
 func init() {
 	ScratchCmd.AddCommand(moveCmd)
+	actions.RegisterNirimgrHandler("ScratchpadMove", scratchpadMove)
+}
+
+// listWindows is a variable that points to connection.ListWindows, allowing us to mock it in tests.
+var listWindows = connection.ListWindows
+
+// focusedWindow is a variable that points to getFocusedWindow, allowing us to mock it in tests.
+var focusedWindow = getFocusedWindow
+
+// scratchpadMove handles the ScratchpadMove action, moving the window with the given ID, or the focused window,
+// to the scratchpad workspace.
+func scratchpadMove(a actions.Action) error {
+	action, err := actions.As[actions.ScratchpadMove](a)
+	if err != nil {
+		return err
+	}
+	window, err := findWindow(action.ID)
+	if err != nil {
+		return err
+	}
+	scratchpad, err := getWorkspace(config.Config.ScratchpadWorkspace)
+	if err != nil {
+		return err
+	}
+	moveWindowToScratchpad(window, scratchpad)
+	return nil
+}
+
+// findWindow returns the window with the given ID, or the focused window if the ID is 0.
+func findWindow(id uint64) (*models.Window, error) {
+	if id == 0 {
+		window, err := focusedWindow()
+		if err != nil {
+			return nil, err
+		}
+		if window == nil {
+			return nil, errors.New("no focused window")
+		}
+		return window, nil
+	}
+	windows, err := listWindows()
+	if err != nil {
+		return nil, err
+	}
+	return common.FilterWindowsChain(windows, func(w *models.Window) bool {
+		return w.ID == id
+	}).First()
 }
 
 // moveToScratchpad moves the currently focused window to the scratchpad workspace.
@@ -29,13 +82,35 @@ func moveToScratchpad() {
 	// 2. get focused window
 	// 3. move window to floating
 	// 4. move floating window to scratchpad workspace, focus=false
-	scratchpad, _ := getWorkspace(config.Config.ScratchpadWorkspace)
-	focusedWindow, _ := getFocusedWindow()
+	// 5. remember the window and its original floating size and position
+	scratchpad, err := getWorkspace(config.Config.ScratchpadWorkspace)
+	if err != nil {
+		slog.Error("Could not get scratchpad workspace", "error", err.Error())
+		return
+	}
+	window, err := findWindow(0)
+	if err != nil {
+		slog.Error("Could not get focused window", "error", err.Error())
+		return
+	}
+
+	moveWindowToScratchpad(window, scratchpad)
+}
+
+// moveWindowToScratchpad hides the window on the scratchpad workspace, and remembers its geometry.
+func moveWindowToScratchpad(window *models.Window, scratchpad *models.Workspace) {
+	hideWindow(window, scratchpad)
+	updateState(func(s *state.State) {
+		s.AddScratchpadWindow(state.NewScratchpadWindow(window))
+	})
+}
 
+// hideWindow moves the window to the scratchpad workspace as a floating window, without focusing it.
+func hideWindow(window *models.Window, scratchpad *models.Workspace) {
 	actionList := []actions.Action{
 		actions.MoveWindowToWorkspace{
 			AName:    actions.AName{Name: "MoveWindowToWorkspace"},
-			WindowID: focusedWindow.ID,
+			WindowID: window.ID,
 			Reference: actions.WorkspaceReferenceArg{
 				ID: scratchpad.ID,
 			},
@@ -43,11 +118,11 @@ func moveToScratchpad() {
 		},
 		actions.MoveWindowToFloating{
 			AName: actions.AName{Name: "MoveWindowToFloating"},
-			ID:    focusedWindow.ID,
+			ID:    window.ID,
 		},
 	}
 
 	for _, action := range actionList {
-		connection.PerformAction(action)
+		performAction(action)
 	}
 }
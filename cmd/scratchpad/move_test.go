@@ -0,0 +1,48 @@
+package scratchpad
+
+import (
+	"testing"
+
+	"github.com/soderluk/nirimgr/actions"
+	"github.com/soderluk/nirimgr/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScratchpadMoveNoFocusedWindow(t *testing.T) {
+	originalFocusedWindow := focusedWindow
+	defer func() { focusedWindow = originalFocusedWindow }()
+
+	// niri answers null when no window is focused, which must not panic the events daemon.
+	focusedWindow = func() (*models.Window, error) { return nil, nil }
+	err := actions.PerformNirimgrAction(actions.ScratchpadMove{AName: actions.AName{Name: "ScratchpadMove"}})
+	assert.EqualError(t, err, "no focused window")
+}
+
+func TestFindWindow(t *testing.T) {
+	originalFocusedWindow := focusedWindow
+	originalListWindows := listWindows
+	defer func() {
+		focusedWindow = originalFocusedWindow
+		listWindows = originalListWindows
+	}()
+
+	// The focused window isn't requested when the ID is given.
+	focusedWindow = func() (*models.Window, error) {
+		t.Fatal("focused window requested with an ID")
+		return nil, nil
+	}
+	listWindows = func() ([]*models.Window, error) {
+		return []*models.Window{{ID: 1}, {ID: 2, Title: "notes"}}, nil
+	}
+	window, err := findWindow(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "notes", window.Title)
+
+	_, err = findWindow(3)
+	assert.Error(t, err)
+
+	focusedWindow = func() (*models.Window, error) { return &models.Window{ID: 1}, nil }
+	window, err = findWindow(0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), window.ID)
+}
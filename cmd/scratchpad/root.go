@@ -12,7 +12,10 @@ var ScratchCmd = &cobra.Command{
 	Long: `An i3wm inspired simple scratchpad functionality for Niri.
 		nirimgr scratch move - moves the currently focused window to the scratchpad workspace.
 		nirimgr scratch show - moves the last window in the scratchpad workspace to the currently focused workspace.
-		nirimgr scratch spawn-or-focus app-id - Spawns the specified app or focuses it if it's already running. Requires configuration for the commands and app IDs.`,
+		nirimgr scratch spawn-or-focus app-id - Spawns the specified app or focuses it if it's already running. Requires configuration for the commands and app IDs.
+		nirimgr scratch hide - moves the visible windows that came from the scratchpad back to the scratchpad workspace.
+		nirimgr scratch toggle - hides the visible scratchpad windows, or shows a window from the scratchpad if none are visible.
+		nirimgr scratch toggle name - Toggles the named scratchpad. Spawns the window if it's missing, shows it if it's hidden, or hides it if it's visible.`,
 }
 
 func init() {
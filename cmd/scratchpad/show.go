@@ -1,124 +1,176 @@
 package scratchpad
 
 import (
+	"encoding/json"
 	"errors"
-	"fmt"
 	"log/slog"
-	"strconv"
-	"strings"
 
 	"github.com/soderluk/nirimgr/actions"
 	"github.com/soderluk/nirimgr/config"
-	"github.com/soderluk/nirimgr/internal/common"
-	"github.com/soderluk/nirimgr/internal/connection"
+	"github.com/soderluk/nirimgr/internal/launcher"
+	"github.com/soderluk/nirimgr/internal/state"
 	"github.com/soderluk/nirimgr/models"
 	"github.com/spf13/cobra"
 )
 
 var showCmd = &cobra.Command{
-	Use:          "show",
-	Short:        "Show a window from the scratchpad",
-	Long:         `Moves the last window from the scratchpad workspace to the currently active workspace. This requires niri to have a named workspace called "scratchpad" (configurable in the config.json). See README.md for more information.`,
+	Use:   "show",
+	Short: "Show a window from the scratchpad",
+	Long: `Moves the most recently used window from the scratchpad workspace to the currently active workspace. This requires niri to have a named workspace called "scratchpad" (configurable in the config.json). See README.md for more information.
+
+With --cycle, the currently shown scratchpad window is hidden, and the next one is shown in most recently used order.
+Repeating the command cycles through all the scratchpad windows.`,
 	SilenceUsage: true, // If there's an error during running the command, don't show usage.
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return showScratchpad()
+		return showScratchpad(cycle)
 	},
 }
 
+// cycle tells whether to cycle to the next scratchpad window instead of showing one.
+var cycle bool
+
 func init() {
+	showCmd.Flags().BoolVar(&cycle, "cycle", false, "hide the shown scratchpad window, and show the next one")
 	ScratchCmd.AddCommand(showCmd)
+	actions.RegisterNirimgrHandler("ScratchpadShow", func(a actions.Action) error {
+		action, err := actions.As[actions.ScratchpadShow](a)
+		if err != nil {
+			return err
+		}
+		return showScratchpad(action.Cycle)
+	})
 }
 
-// showScratchpad moves the last window from the scratchpad workspace to the currently active workspace.
+// showScratchpad moves the most recently used window from the scratchpad workspace to the currently active workspace.
+//
+// If there are more windows in the scratchpad, the launcher is opened to select the window. With cycle, the shown
+// scratchpad window is hidden and the next window is shown instead.
 //
 // This requires niri to have a named workspace called "scratchpad". See README.md for more information.
-func showScratchpad() error {
+func showScratchpad(cycle bool) error {
 	// Show scratchpad:
-	// 1. get current workspace
+	// 1. get the workspace to show the window on
 	// 2. list all windows in scratchpad workspace
-	// 3. take latest window and move it to the current workspace
+	// 3. take the most recently used window and move it to the workspace
 
-	scratchpad, _ := getWorkspace(config.Config.ScratchpadWorkspace)
-	focusedWorkspace, _ := getWorkspace("focused")
-
-	windows, err := connection.ListWindows()
+	s, windows, err := loadState()
+	if err != nil {
+		return err
+	}
+	scratchpad, err := getWorkspace(config.Config.ScratchpadWorkspace)
+	if err != nil {
+		return err
+	}
+	workspace, err := getShowWorkspace()
 	if err != nil {
-		slog.Error("Could not unmarshal windows", "error", err.Error())
-		return errors.New("could not unmarshal windows")
+		return err
 	}
 	// Filter the scratchpad windows.
-	workspaceWindows := filterWindows(windows, func(w *models.Window) bool {
+	workspaceWindows := s.SortScratchpadWindows(filterWindows(windows, func(w *models.Window) bool {
 		return w.WorkspaceID == scratchpad.ID
+	}))
+
+	if cycle {
+		return cycleScratchpad(s, windows, workspaceWindows, scratchpad, workspace)
+	}
+
+	if len(workspaceWindows) == 0 {
+		return nil
+	}
+	window := workspaceWindows[0]
+	// If we have more than one window in the scratchpad, open a launcher to select the window.
+	if len(workspaceWindows) > 1 {
+		selected, err := selectWindow(workspaceWindows)
+		if errors.Is(err, launcher.ErrCancelled) {
+			slog.Debug("Launcher cancelled, not showing any window")
+			return nil
+		}
+		if err != nil {
+			slog.Error("Could not select window", "error", err.Error())
+			return errors.New("could not select window")
+		}
+		window = selected
+	}
+
+	showWindow(window, workspace, nil)
+	updateState(func(s *state.State) {
+		s.Prune(windows)
+		s.AddScratchpadWindow(state.NewScratchpadWindow(window))
+		s.TouchScratchpadWindow(window.ID)
 	})
+	return nil
+}
 
-	var window *models.Window
-	// Return the last window in the list, if we have any.
-	if len(workspaceWindows) > 0 {
-		// If we have more than one window in the scratchpad, open a launcher to select the window.
-		if len(workspaceWindows) > 1 {
-			// Default the launcher to fuzzel at /usr/bin/fuzzel.
-			launcher := "/usr/bin/fuzzel"
-			// Default to -d -w 50 (--dmenu --width 50).
-			launcherOptions := "-d -w 50"
-			// If the launcher and launcher options are configured, use those instead.
-			if config.Config.Launcher != "" {
-				launcher = config.Config.Launcher
-			}
-			if config.Config.LauncherOptions != "" {
-				launcherOptions = config.Config.LauncherOptions
-			}
-			// Build the list of windows to pass to the launcher.
-			command := ""
-			for idx, window := range workspaceWindows {
-				command += fmt.Sprintf("%d - %s\n", idx, window.Title)
-			}
-			// Build the full command to run.
-			fullCommand := fmt.Sprintf("echo \"%s\" | %s %s | awk '{print $1}'", command, launcher, launcherOptions)
-			result, err := common.RunCommand(fullCommand)
-			if err != nil {
-				slog.Error("Error running command", slog.String("command", fullCommand), slog.Any("error", err.Error()))
-				return errors.New("could not run command")
-			}
-			idx, err := strconv.ParseUint(strings.Replace(string(result), "\n", "", 1), 10, 64)
-			if err != nil {
-				slog.Error("Could not convert result to uint64", slog.String("result", string(result)), slog.Any("error", err.Error()))
-				return errors.New("could not run command")
-			}
-			window = workspaceWindows[idx]
-		} else {
-			window = workspaceWindows[len(workspaceWindows)-1]
+// cycleScratchpad hides the shown scratchpad windows, and shows the window following them in most recently used order.
+//
+// The order isn't changed while cycling, so repeated cycling goes through all the hidden windows.
+// If there are no other hidden windows, the shown windows are only hidden.
+func cycleScratchpad(s *state.State, windows, hidden []*models.Window, scratchpad, workspace *models.Workspace) error {
+	visible, err := visibleScratchpadWindows(s, windows)
+	if err != nil {
+		return err
+	}
+	var current uint64
+	if len(visible) > 0 {
+		current = s.SortScratchpadWindows(visible)[0].ID
+	}
+	for _, window := range visible {
+		tracked, _ := s.ScratchpadWindow(window.ID)
+		restoreGeometry(window, tracked)
+		hideWindow(window, scratchpad)
+	}
+
+	next := s.NextScratchpadWindow(current, hidden)
+	if next != nil {
+		slog.Debug("Cycling scratchpad window", "from", current, "to", next.ID)
+		showWindow(next, workspace, nil)
+	}
+	updateState(func(s *state.State) {
+		s.Prune(windows)
+		if next != nil {
+			s.AddScratchpadWindow(state.NewScratchpadWindow(next))
 		}
+	})
+	return nil
+}
 
-		actionList := []actions.Action{
-			actions.MoveWindowToWorkspace{
-				AName:    actions.AName{Name: "MoveWindowToWorkspace"},
-				WindowID: window.ID,
-				Reference: actions.WorkspaceReferenceArg{
-					ID: focusedWorkspace.ID,
-				},
-				Focus: true,
-			},
-			// Manually focus the window, since the `Focus: true` does nothing in the above action.
-			actions.FocusWindow{
-				AName: actions.AName{Name: "FocusWindow"},
-				ID:    window.ID,
+// showWindow moves the window to the given workspace as a floating window, and focuses it.
+//
+// If configured, the window is centered on the workspace's output. The configured showScratchpadActions are performed on the window, followed by the given extra actions.
+func showWindow(window *models.Window, workspace *models.Workspace, extraActions map[string]json.RawMessage) {
+	var actionList []actions.Action
+	if !window.IsFloating {
+		actionList = append(actionList, actions.MoveWindowToFloating{
+			AName: actions.AName{Name: "MoveWindowToFloating"},
+			ID:    window.ID,
+		})
+	}
+	actionList = append(actionList,
+		actions.MoveWindowToWorkspace{
+			AName:    actions.AName{Name: "MoveWindowToWorkspace"},
+			WindowID: window.ID,
+			Reference: actions.WorkspaceReferenceArg{
+				ID: workspace.ID,
 			},
-		}
+			Focus: true,
+		},
+		// Manually focus the window, since the `Focus: true` does nothing in the above action.
+		actions.FocusWindow{
+			AName: actions.AName{Name: "FocusWindow"},
+			ID:    window.ID,
+		},
+	)
 
-		// If we have actions, append them to the list.
-		if len(config.Config.ShowScratchpadActions) > 0 {
-			for _, action := range actions.ParseRawActions(config.Config.ShowScratchpadActions) {
-				a := actions.HandleDynamicIDs(action, models.PossibleKeys{
-					ID:       window.ID,
-					WindowID: window.ID,
-				})
-				actionList = append(actionList, a)
-			}
-		}
+	if config.Config.ScratchpadShow.Center {
+		actionList = append(actionList, centerWindow(window, workspace)...)
+	}
 
-		for _, action := range actionList {
-			connection.PerformAction(action)
-		}
+	// If we have actions, append them to the list.
+	actionList = append(actionList, windowActions(window, config.Config.ShowScratchpadActions)...)
+	actionList = append(actionList, windowActions(window, extraActions)...)
+
+	for _, action := range actionList {
+		performAction(action)
 	}
-	return nil
+	performWindowSteps(window, config.Config.ShowScratchpadActions, extraActions)
 }
@@ -0,0 +1,139 @@
+package scratchpad
+
+import (
+	"testing"
+
+	"github.com/soderluk/nirimgr/actions"
+	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/internal/state"
+	"github.com/soderluk/nirimgr/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockNiri mocks the windows and workspaces of niri, and the state file, returning the performed actions.
+//
+// The windows and workspaces are read through the given pointers, so the tests can change them between the calls.
+func mockNiri(t *testing.T, windows *[]*models.Window, workspaces *[]*models.Workspace) *[]actions.Action {
+	t.Helper()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	originalConfig := config.Config
+	originalListWindows := listWindows
+	originalListWorkspaces := listWorkspaces
+	originalPerformAction := performAction
+	t.Cleanup(func() {
+		config.Config = originalConfig
+		listWindows = originalListWindows
+		listWorkspaces = originalListWorkspaces
+		performAction = originalPerformAction
+	})
+
+	config.Config = &models.Config{}
+	listWindows = func() ([]*models.Window, error) { return *windows, nil }
+	listWorkspaces = func() ([]*models.Workspace, error) { return *workspaces, nil }
+	var performed []actions.Action
+	performAction = func(action actions.Action) bool {
+		performed = append(performed, action)
+		return true
+	}
+	return &performed
+}
+
+// shownWindows returns the IDs of the windows moved to the given workspace by the actions.
+func shownWindows(performed []actions.Action, workspace uint64) []uint64 {
+	var ids []uint64
+	for _, action := range performed {
+		if move, ok := action.(actions.MoveWindowToWorkspace); ok && move.Reference.ID == workspace {
+			ids = append(ids, move.WindowID)
+		}
+	}
+	return ids
+}
+
+func TestCycleScratchpad(t *testing.T) {
+	windows := []*models.Window{
+		{ID: 1, WorkspaceID: 10},
+		{ID: 2, WorkspaceID: 10},
+		{ID: 3, WorkspaceID: 10},
+	}
+	workspaces := []*models.Workspace{
+		{ID: 1, IsActive: true, IsFocused: true},
+		{ID: 10, Name: "scratchpad"},
+	}
+	performed := mockNiri(t, &windows, &workspaces)
+	// Window 2 is the most recently used one, so it's shown first.
+	assert.NoError(t, state.Update(func(s *state.State) {
+		s.Scratchpad = []state.ScratchpadWindow{{ID: 2}, {ID: 3}, {ID: 1}}
+	}))
+
+	var order []uint64
+	for range 4 {
+		*performed = nil
+		assert.NoError(t, showScratchpad(true))
+		shown := shownWindows(*performed, 1)
+		assert.Len(t, shown, 1)
+		order = append(order, shown...)
+		// niri moves the windows, so update the workspaces of the windows like it would.
+		for _, window := range windows {
+			window.WorkspaceID = 10
+			if window.ID == shown[0] {
+				window.WorkspaceID = 1
+			}
+		}
+	}
+	// Cycling goes through all the windows without changing the order.
+	assert.Equal(t, []uint64{2, 3, 1, 2}, order)
+
+	s, err := state.Load()
+	assert.NoError(t, err)
+	var tracked []uint64
+	for _, w := range s.Scratchpad {
+		tracked = append(tracked, w.ID)
+	}
+	assert.Equal(t, []uint64{2, 3, 1}, tracked)
+}
+
+func TestCycleScratchpadHidesOnlyWindow(t *testing.T) {
+	windows := []*models.Window{{ID: 1, WorkspaceID: 1}}
+	workspaces := []*models.Workspace{
+		{ID: 1, IsActive: true, IsFocused: true},
+		{ID: 10, Name: "scratchpad"},
+	}
+	performed := mockNiri(t, &windows, &workspaces)
+	assert.NoError(t, state.Update(func(s *state.State) {
+		s.Scratchpad = []state.ScratchpadWindow{{ID: 1}}
+	}))
+
+	// Without other hidden windows, the shown window is only hidden.
+	assert.NoError(t, showScratchpad(true))
+	assert.Equal(t, []uint64{1}, shownWindows(*performed, 10))
+	assert.Empty(t, shownWindows(*performed, 1))
+}
+
+func TestGetShowWorkspace(t *testing.T) {
+	var windows []*models.Window
+	workspaces := []*models.Workspace{
+		{ID: 1, Output: "DP-1", IsActive: true, IsFocused: true},
+		{ID: 2, Output: "HDMI-A-1", IsActive: true},
+		{ID: 3, Output: "HDMI-A-1"},
+	}
+	mockNiri(t, &windows, &workspaces)
+	originalFocusedOutput := focusedOutput
+	defer func() { focusedOutput = originalFocusedOutput }()
+	focusedOutput = func() (*models.Output, error) { return &models.Output{Name: "HDMI-A-1"}, nil }
+
+	// By default, the windows are shown on the focused workspace.
+	workspace, err := getShowWorkspace()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), workspace.ID)
+
+	// With onFocusedOutput, they're shown on the active workspace of the focused output.
+	config.Config.ScratchpadShow.OnFocusedOutput = true
+	workspace, err = getShowWorkspace()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), workspace.ID)
+
+	focusedOutput = func() (*models.Output, error) { return &models.Output{Name: "eDP-1"}, nil }
+	_, err = getShowWorkspace()
+	assert.EqualError(t, err, "no active workspace on output 'eDP-1'")
+}
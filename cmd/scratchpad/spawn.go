@@ -1,13 +1,18 @@
 package scratchpad
 
 import (
+	"errors"
 	"log/slog"
 	"os"
-	"strings"
+	"slices"
 
 	"github.com/soderluk/nirimgr/actions"
 	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/events"
+	"github.com/soderluk/nirimgr/internal/common"
 	"github.com/soderluk/nirimgr/internal/connection"
+	"github.com/soderluk/nirimgr/internal/launcher"
+	"github.com/soderluk/nirimgr/internal/state"
 	"github.com/soderluk/nirimgr/models"
 	"github.com/spf13/cobra"
 )
@@ -15,8 +20,15 @@ import (
 var spawnCmd = &cobra.Command{
 	Use:   "spawn-or-focus [app-id]",
 	Short: "Spawn an app or focus it if already running",
-	Long:  `Spawns the specified app or focuses it if it's already running. Requires configuration for the commands and app IDs.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Spawns the specified app or focuses it if it's already running. Requires configuration for the commands and app IDs.
+
+If multiple windows match, repeated invocations cycle through them, and after the last window the previously
+focused window is focused again. Set "select" in the spawnOrFocus configuration to select the window with the launcher instead.
+
+Each command can set a mode: "focus" (the default) focuses the window wherever it is, "bring" and "bring-floating" move
+the window to the focused workspace and send it back on the next invocation, and "scratchpad" toggles the window
+between the scratchpad workspace and the focused workspace.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		spawnOrFocus(args[0])
 	},
@@ -26,46 +38,368 @@ func init() {
 	ScratchCmd.AddCommand(spawnCmd)
 }
 
+// eventStream is a variable that points to events.EventStream, allowing us to mock it in tests.
+var eventStream = events.EventStream
+
 // spawnOrFocus will spawn a specified window or focus it if it's already open.
 //
 // This is heavily inspired by the discussion over here: https://github.com/YaLTeR/niri/discussions/329#discussioncomment-13378697
 // I adapted the functionality to be supported in nirimgr. The commands and app id's are configurable in the config.json.
+//
+// Depending on the mode of the command, an already open window is focused, brought to the focused workspace, or
+// toggled like a scratchpad.
 func spawnOrFocus(arg string) {
-	windows, err := connection.ListWindows()
+	windows, err := listWindows()
 	if err != nil {
 		slog.Error("Could not list windows", "error", err.Error())
 		os.Exit(1)
 	}
 
-	var matchedWindow *models.Window
-	command, err := config.Config.SpawnOrFocus.Command(arg)
+	command, err := config.Config.SpawnOrFocus.Lookup(arg)
 	if err != nil {
 		slog.Error("Could not get command", "error", err.Error())
 		os.Exit(1)
 	}
+	matchingWindows := filterWindows(windows, func(w *models.Window) bool {
+		return spawnOrFocusMatches(w, arg, command)
+	})
+	if len(matchingWindows) == 0 {
+		if err := spawnWindow(arg, windows, command); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	ordered := models.WindowSlice{Windows: matchingWindows}.SortByFocus()
+	if config.Config.SpawnOrFocus.Order == "id" {
+		ordered = ordered.SortByID()
+	}
+
+	switch command.Mode {
+	case models.SpawnOrFocusModeBring, models.SpawnOrFocusModeBringFloating:
+		err = bringWindow(windows, ordered.Windows, command)
+	case models.SpawnOrFocusModeScratchpad:
+		err = toggleScratchpadWindow(arg, ordered.Windows, command.Actions)
+	default:
+		err = focusWindow(arg, windows, ordered.Windows)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// spawnWindow spawns the command.
+//
+// If the command has spawn actions, we wait for the spawned window to appear, and perform the actions on it.
+// This way the new window can e.g. be floated and sized without a separate rule in the events daemon.
+func spawnWindow(arg string, windows []*models.Window, command models.SpawnOrFocusCommand) error {
+	// In a dry-run the window never appears, so there's nothing to wait for.
+	if len(command.SpawnActions) == 0 || connection.DryRun != nil {
+		slog.Debug("Didn't match any window, spawning command", "cmd", command.Command)
+		return spawnCommand(command)
+	}
+	window, err := spawnAndWait(windows, command, func(w *models.Window) bool {
+		return spawnOrFocusMatches(w, arg, command)
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("Spawned window appeared, performing actions", "window", window.ID)
+	for _, action := range windowActions(window, command.SpawnActions) {
+		performAction(action)
+	}
+	performWindowSteps(window, command.SpawnActions)
+	return nil
+}
+
+// spawnAndWait spawns the command, and waits for a new window matching the given function to appear.
+//
+// The windows are the currently open windows, which are never considered to be the spawned window.
+func spawnAndWait(windows []*models.Window, command models.SpawnOrFocusCommand, matches func(*models.Window) bool) (*models.Window, error) {
+	timeout, err := command.WaitTimeout()
+	if err != nil {
+		slog.Error("Could not get timeout", "error", err.Error())
+		return nil, err
+	}
+
+	// Listen to the events before spawning, so we don't miss the new window.
+	stream, err := eventStream()
+	if err != nil {
+		slog.Error("Could not get events", "error", err.Error())
+		return nil, errors.New("could not get events")
+	}
+	existing := make(map[uint64]struct{}, len(windows))
 	for _, window := range windows {
-		for _, rule := range config.Config.SpawnOrFocus.Rules {
-			if rule.WindowMatches(*window) {
-				slog.Debug("Trying window", "appId", window.AppID)
-				// If the app id doesn't contain the given argument, skip this window.
-				if !strings.Contains(window.AppID, arg) {
-					continue
-				}
-				slog.Debug("Window matches rule", "window", window.AppID, "rule", rule)
-				matchedWindow = window
-				break
+		existing[window.ID] = struct{}{}
+	}
+
+	slog.Debug("Didn't match any window, spawning command and waiting for the window", "cmd", command.Command, "timeout", timeout)
+	if err := spawnCommand(command); err != nil {
+		return nil, err
+	}
+	window, err := events.WaitForWindow(stream, timeout, func(w *models.Window) bool {
+		_, ok := existing[w.ID]
+		return !ok && matches(w)
+	})
+	if err != nil {
+		slog.Error("Spawned window didn't appear", "cmd", command.Command, "error", err.Error())
+		return nil, err
+	}
+	return window, nil
+}
+
+// spawnCommand spawns the command with niri's Spawn action.
+//
+// If the command has a working directory or environment variables, it's started directly instead,
+// since the Spawn action doesn't support them.
+func spawnCommand(command models.SpawnOrFocusCommand) error {
+	if command.Dir == "" && len(command.Env) == 0 {
+		performAction(actions.Spawn{AName: actions.AName{Name: "Spawn"}, Command: command.Command})
+		return nil
+	}
+	if connection.DryRun != nil {
+		// Print the command like the Exec action instead of starting it.
+		performAction(actions.Exec{AName: actions.AName{Name: "Exec"}, Command: command.Command, Dir: command.Dir, Env: command.Env})
+		return nil
+	}
+	if err := common.StartDetached(command.Command, command.Dir, command.Env); err != nil {
+		slog.Error("Could not start command", "cmd", command.Command, "error", err.Error())
+		return errors.New("could not start command")
+	}
+	return nil
+}
+
+// focusWindow focuses one of the matching windows.
+//
+// If multiple windows match, the windows are cycled in the configured order. The order is remembered in the state,
+// so focusing the windows doesn't change it. When the last window of the cycle is focused, we go back to the window
+// that was focused before the cycle started.
+func focusWindow(arg string, windows, matchingWindows []*models.Window) error {
+	ids := make([]uint64, 0, len(matchingWindows))
+	var focused *models.Window
+	for _, window := range matchingWindows {
+		ids = append(ids, window.ID)
+		if window.IsFocused {
+			focused = window
+		}
+	}
+
+	s, err := state.Load()
+	if err != nil {
+		slog.Error("Could not load state, not remembering the cycle", "error", err.Error())
+		s = &state.State{}
+	}
+
+	if focused == nil {
+		// Start a new cycle from the first window, or from the selected window.
+		target, err := pickWindow(matchingWindows)
+		if err != nil || target == nil {
+			return err
+		}
+		if config.Config.SpawnOrFocus.Select {
+			ids = []uint64{target.ID}
+		}
+		cycle := state.FocusCycle{Windows: ids}
+		for _, window := range windows {
+			if window.IsFocused {
+				cycle.Previous = window.ID
 			}
 		}
+		slog.Debug("Focusing matched window", "window", target.Title)
+		performAction(actions.FocusWindow{AName: actions.AName{Name: "FocusWindow"}, ID: target.ID})
+		updateState(func(s *state.State) { s.SetFocusCycle(arg, cycle) })
+		return nil
+	}
+
+	cycle := s.FocusCycles[arg].Update(ids)
+	if next, ok := cycle.Next(focused.ID); ok && !config.Config.SpawnOrFocus.Select {
+		slog.Debug("Cycling to the next matched window", "window", next)
+		performAction(actions.FocusWindow{AName: actions.AName{Name: "FocusWindow"}, ID: next})
+		updateState(func(s *state.State) { s.SetFocusCycle(arg, cycle) })
+		return nil
+	}
+
+	// The cycle is complete, go back to the window focused before the cycle.
+	updateState(func(s *state.State) { delete(s.FocusCycles, arg) })
+	for _, window := range windows {
+		if cycle.Previous != 0 && window.ID == cycle.Previous {
+			performAction(actions.FocusWindow{AName: actions.AName{Name: "FocusWindow"}, ID: window.ID})
+			return nil
+		}
+	}
+	performAction(actions.FocusWindowPrevious{AName: actions.AName{Name: "FocusWindowPrevious"}})
+	return nil
+}
+
+// bringWindow moves one of the matching windows to the focused workspace, and focuses it.
+//
+// If a brought window is already on the focused workspace, it's sent back to the workspace it came from.
+func bringWindow(windows, matchingWindows []*models.Window, command models.SpawnOrFocusCommand) error {
+	workspaces, err := listWorkspaces()
+	if err != nil {
+		slog.Error("Could not list workspaces", "error", err.Error())
+		return errors.New("could not list workspaces")
+	}
+	focusedWorkspace, err := common.FilterWorkspacesChain(workspaces, func(w *models.Workspace) bool {
+		return w.IsFocused
+	}).First()
+	if err != nil {
+		slog.Error("Could not get focused workspace", "error", err.Error())
+		return err
+	}
+
+	s, err := state.Load()
+	if err != nil {
+		slog.Error("Could not load state, not remembering the origin", "error", err.Error())
+		s = &state.State{}
+	}
+	s.Prune(windows)
+
+	for _, window := range matchingWindows {
+		if window.WorkspaceID != focusedWorkspace.ID {
+			continue
+		}
+		if brought, ok := s.BroughtWindow(window.ID); ok {
+			sendBackWindow(window, brought, workspaces)
+			updateState(func(s *state.State) {
+				s.Prune(windows)
+				s.RemoveBroughtWindow(window.ID)
+			})
+			return nil
+		}
+		// The window is already on the focused workspace, so there's nothing to bring.
+		slog.Debug("Window already on the focused workspace, focusing it", "window", window.ID)
+		performAction(actions.FocusWindow{AName: actions.AName{Name: "FocusWindow"}, ID: window.ID})
+		return nil
+	}
+
+	window, err := pickWindow(matchingWindows)
+	if err != nil || window == nil {
+		return err
+	}
+	brought := state.BroughtWindow{ID: window.ID, WorkspaceID: window.WorkspaceID, WasFloating: window.IsFloating}
+	for _, workspace := range workspaces {
+		if workspace.ID == window.WorkspaceID {
+			brought.Output = workspace.Output
+		}
+	}
+
+	var actionList []actions.Action
+	if command.Mode == models.SpawnOrFocusModeBringFloating && !window.IsFloating {
+		actionList = append(actionList, actions.MoveWindowToFloating{
+			AName: actions.AName{Name: "MoveWindowToFloating"},
+			ID:    window.ID,
+		})
+	}
+	actionList = append(actionList,
+		actions.MoveWindowToWorkspace{
+			AName:     actions.AName{Name: "MoveWindowToWorkspace"},
+			WindowID:  window.ID,
+			Reference: actions.WorkspaceReferenceArg{ID: focusedWorkspace.ID},
+			Focus:     true,
+		},
+		actions.FocusWindow{
+			AName: actions.AName{Name: "FocusWindow"},
+			ID:    window.ID,
+		},
+	)
+	actionList = append(actionList, windowActions(window, command.Actions)...)
+
+	slog.Debug("Bringing window to the focused workspace", "window", window.ID, "from", window.WorkspaceID)
+	for _, action := range actionList {
+		performAction(action)
+	}
+	performWindowSteps(window, command.Actions)
+	updateState(func(s *state.State) {
+		s.Prune(windows)
+		s.AddBroughtWindow(brought)
+	})
+	return nil
+}
+
+// sendBackWindow moves the brought window back to the workspace it came from.
+//
+// niri removes an unnamed workspace when its last window is moved away, so if the original workspace
+// no longer exists, the window is moved to the empty workspace at the end of the original output.
+func sendBackWindow(window *models.Window, brought state.BroughtWindow, workspaces []*models.Workspace) {
+	var target *models.Workspace
+	for _, workspace := range workspaces {
+		if workspace.ID == brought.WorkspaceID {
+			target = workspace
+			break
+		}
+		if brought.Output != "" && workspace.Output == brought.Output && (target == nil || workspace.Idx > target.Idx) {
+			target = workspace
+		}
+	}
+	if target == nil {
+		slog.Warn("Could not find the workspace the window came from, not sending it back", "window", window.ID)
+		return
+	}
+
+	var actionList []actions.Action
+	if !brought.WasFloating && window.IsFloating {
+		actionList = append(actionList, actions.MoveWindowToTiling{
+			AName: actions.AName{Name: "MoveWindowToTiling"},
+			ID:    window.ID,
+		})
+	}
+	actionList = append(actionList, actions.MoveWindowToWorkspace{
+		AName:     actions.AName{Name: "MoveWindowToWorkspace"},
+		WindowID:  window.ID,
+		Reference: actions.WorkspaceReferenceArg{ID: target.ID},
+		Focus:     false,
+	})
+
+	slog.Debug("Sending window back", "window", window.ID, "workspace", target.ID)
+	for _, action := range actionList {
+		performAction(action)
+	}
+}
+
+// pickWindow returns the first of the matching windows, or opens the launcher to select the window if
+// the spawnOrFocus select option is set.
+//
+// Returns nil if the launcher was cancelled.
+func pickWindow(matchingWindows []*models.Window) (*models.Window, error) {
+	if !config.Config.SpawnOrFocus.Select || len(matchingWindows) == 1 {
+		return matchingWindows[0], nil
+	}
+	selected, err := selectWindow(matchingWindows)
+	if errors.Is(err, launcher.ErrCancelled) {
+		slog.Debug("Launcher cancelled, not focusing any window")
+		return nil, nil
 	}
-	if matchedWindow != nil {
-		slog.Debug("matched window", "window", matchedWindow.Title)
-		if matchedWindow.IsFocused {
-			connection.PerformAction(actions.FocusWindowPrevious{AName: actions.AName{Name: "FocusWindowPrevious"}})
-		} else {
-			connection.PerformAction(actions.FocusWindow{AName: actions.AName{Name: "FocusWindow"}, ID: matchedWindow.ID})
+	if err != nil {
+		slog.Error("Could not select window", "error", err.Error())
+		return nil, errors.New("could not select window")
+	}
+	return selected, nil
+}
+
+// spawnOrFocusMatches checks if the window matches the command with the given key.
+//
+// The command's own match defaults to the app id being exactly the key. For backwards compatibility, the
+// deprecated spawnOrFocus rules must also match, if configured and the command doesn't have its own match.
+func spawnOrFocusMatches(window *models.Window, key string, command models.SpawnOrFocusCommand) bool {
+	if !command.Rule(key).WindowMatches(*window) {
+		return false
+	}
+	if len(command.Match) == 0 && len(config.Config.SpawnOrFocus.Rules) > 0 {
+		if !slices.ContainsFunc(config.Config.SpawnOrFocus.Rules, func(rule models.Rule) bool {
+			return rule.WindowMatches(*window)
+		}) {
+			return false
 		}
-	} else {
-		slog.Debug("Didn't match any window, spawning command", "cmd", command)
-		connection.PerformAction(actions.Spawn{AName: actions.AName{Name: "Spawn"}, Command: command})
 	}
+	matched, err := events.EvaluateCondition(command.When, window)
+	if err != nil {
+		slog.Error("Could not evaluate condition", "key", key, "error", err.Error())
+		return false
+	}
+	if matched {
+		slog.Debug("Window matches", "window", window.AppID, "key", key)
+	}
+	return matched
 }
@@ -0,0 +1,260 @@
+package scratchpad
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/soderluk/nirimgr/actions"
+	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/events"
+	"github.com/soderluk/nirimgr/internal/state"
+	"github.com/soderluk/nirimgr/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// focusedWindows returns the IDs of the windows focused by the actions, or 0 for FocusWindowPrevious.
+func focusedWindows(performed []actions.Action) []uint64 {
+	var ids []uint64
+	for _, action := range performed {
+		switch a := action.(type) {
+		case actions.FocusWindow:
+			ids = append(ids, a.ID)
+		case actions.FocusWindowPrevious:
+			ids = append(ids, 0)
+		}
+	}
+	return ids
+}
+
+// focus focuses the window with the given ID like niri would.
+func focus(windows []*models.Window, id uint64) {
+	for _, window := range windows {
+		window.IsFocused = window.ID == id
+	}
+}
+
+func TestFocusWindowCycle(t *testing.T) {
+	windows := []*models.Window{
+		{ID: 1, AppID: "foot"},
+		{ID: 2, AppID: "foot"},
+		{ID: 3, AppID: "foot"},
+		{ID: 9, AppID: "firefox", IsFocused: true},
+	}
+	var workspaces []*models.Workspace
+	performed := mockNiri(t, &windows, &workspaces)
+	config.Config.SpawnOrFocus = models.SpawnOrFocus{
+		Commands: map[string]models.SpawnOrFocusCommand{"foot": {Command: []string{"foot"}}},
+		Order:    "id",
+	}
+
+	// The matching windows are cycled, and then the window focused before the cycle is focused again.
+	var order []uint64
+	for range 5 {
+		*performed = nil
+		spawnOrFocus("foot")
+		focused := focusedWindows(*performed)
+		assert.Len(t, focused, 1)
+		order = append(order, focused...)
+		focus(windows, focused[0])
+	}
+	assert.Equal(t, []uint64{1, 2, 3, 9, 1}, order)
+}
+
+func TestFocusWindowCycleClosedWindow(t *testing.T) {
+	windows := []*models.Window{
+		{ID: 1, AppID: "foot"},
+		{ID: 2, AppID: "foot"},
+		{ID: 3, AppID: "foot"},
+		{ID: 9, AppID: "firefox", IsFocused: true},
+	}
+	var workspaces []*models.Workspace
+	performed := mockNiri(t, &windows, &workspaces)
+	config.Config.SpawnOrFocus = models.SpawnOrFocus{
+		Commands: map[string]models.SpawnOrFocusCommand{"foot": {Command: []string{"foot"}}},
+		Order:    "id",
+	}
+
+	spawnOrFocus("foot")
+	focus(windows, 1)
+
+	// The next window of the cycle closes, so it's skipped.
+	windows = []*models.Window{windows[0], windows[2], windows[3]}
+	spawnOrFocus("foot")
+	focus(windows, 3)
+	spawnOrFocus("foot")
+	assert.Equal(t, []uint64{1, 3, 9}, focusedWindows(*performed))
+
+	// The previous window closes while cycling, so niri's previous window is focused instead.
+	focus(windows, 9)
+	spawnOrFocus("foot")
+	focus(windows, 1)
+	windows = windows[:2]
+	*performed = nil
+	spawnOrFocus("foot")
+	focus(windows, 3)
+	spawnOrFocus("foot")
+	assert.Equal(t, []uint64{3, 0}, focusedWindows(*performed))
+}
+
+func TestBringWindow(t *testing.T) {
+	windows := []*models.Window{
+		{ID: 1, AppID: "foot", WorkspaceID: 2},
+		{ID: 9, AppID: "firefox", WorkspaceID: 1, IsFocused: true},
+	}
+	workspaces := []*models.Workspace{
+		{ID: 1, Idx: 1, Output: "DP-1", IsActive: true, IsFocused: true},
+		{ID: 2, Idx: 2, Output: "DP-1"},
+	}
+	performed := mockNiri(t, &windows, &workspaces)
+	config.Config.SpawnOrFocus = models.SpawnOrFocus{
+		Commands: map[string]models.SpawnOrFocusCommand{
+			"foot": {Command: []string{"foot"}, Mode: models.SpawnOrFocusModeBringFloating},
+		},
+	}
+
+	spawnOrFocus("foot")
+	assert.Equal(t, []actions.Action{
+		actions.MoveWindowToFloating{AName: actions.AName{Name: "MoveWindowToFloating"}, ID: 1},
+		actions.MoveWindowToWorkspace{
+			AName:     actions.AName{Name: "MoveWindowToWorkspace"},
+			WindowID:  1,
+			Reference: actions.WorkspaceReferenceArg{ID: 1},
+			Focus:     true,
+		},
+		actions.FocusWindow{AName: actions.AName{Name: "FocusWindow"}, ID: 1},
+	}, *performed)
+	s, err := state.Load()
+	assert.NoError(t, err)
+	brought, ok := s.BroughtWindow(1)
+	assert.True(t, ok)
+	assert.Equal(t, state.BroughtWindow{ID: 1, WorkspaceID: 2, Output: "DP-1"}, brought)
+
+	// The brought window is on the focused workspace, so it's sent back to its workspace as a tiled window.
+	windows[0].WorkspaceID = 1
+	windows[0].IsFloating = true
+	*performed = nil
+	spawnOrFocus("foot")
+	assert.Equal(t, []actions.Action{
+		actions.MoveWindowToTiling{AName: actions.AName{Name: "MoveWindowToTiling"}, ID: 1},
+		actions.MoveWindowToWorkspace{
+			AName:     actions.AName{Name: "MoveWindowToWorkspace"},
+			WindowID:  1,
+			Reference: actions.WorkspaceReferenceArg{ID: 2},
+		},
+	}, *performed)
+	s, err = state.Load()
+	assert.NoError(t, err)
+	_, ok = s.BroughtWindow(1)
+	assert.False(t, ok)
+}
+
+func TestBringWindowStaleWorkspace(t *testing.T) {
+	windows := []*models.Window{{ID: 1, AppID: "foot", WorkspaceID: 1, IsFocused: true}}
+	// niri removed the workspace 2 the window came from, since it became empty.
+	workspaces := []*models.Workspace{
+		{ID: 1, Idx: 1, Output: "DP-1", IsActive: true, IsFocused: true},
+		{ID: 3, Idx: 2, Output: "DP-1"},
+		{ID: 4, Idx: 1, Output: "HDMI-A-1", IsActive: true},
+	}
+	performed := mockNiri(t, &windows, &workspaces)
+	config.Config.SpawnOrFocus = models.SpawnOrFocus{
+		Commands: map[string]models.SpawnOrFocusCommand{
+			"foot": {Command: []string{"foot"}, Mode: models.SpawnOrFocusModeBring},
+		},
+	}
+	assert.NoError(t, state.Update(func(s *state.State) {
+		s.AddBroughtWindow(state.BroughtWindow{ID: 1, WorkspaceID: 2, Output: "DP-1"})
+	}))
+
+	// The window is sent to the empty workspace at the end of the original output.
+	spawnOrFocus("foot")
+	assert.Equal(t, []actions.Action{
+		actions.MoveWindowToWorkspace{
+			AName:     actions.AName{Name: "MoveWindowToWorkspace"},
+			WindowID:  1,
+			Reference: actions.WorkspaceReferenceArg{ID: 3},
+		},
+	}, *performed)
+	s, err := state.Load()
+	assert.NoError(t, err)
+	_, ok := s.BroughtWindow(1)
+	assert.False(t, ok)
+}
+
+func TestSendBackWindowMissingOutput(t *testing.T) {
+	var windows []*models.Window
+	workspaces := []*models.Workspace{{ID: 4, Idx: 1, Output: "HDMI-A-1", IsActive: true}}
+	performed := mockNiri(t, &windows, &workspaces)
+
+	// Neither the workspace nor its output exist anymore, so the window stays where it is.
+	sendBackWindow(&models.Window{ID: 1}, state.BroughtWindow{ID: 1, WorkspaceID: 2, Output: "DP-1"}, workspaces)
+	assert.Empty(t, *performed)
+}
+
+// mockEventStream mocks the niri event stream, sending the given events when the command is spawned.
+func mockEventStream(t *testing.T, performed *[]actions.Action, sent ...events.Event) {
+	t.Helper()
+	originalEventStream := eventStream
+	t.Cleanup(func() { eventStream = originalEventStream })
+
+	stream := make(chan events.Event, len(sent))
+	eventStream = func() (<-chan events.Event, error) { return stream, nil }
+	performAction = func(action actions.Action) bool {
+		*performed = append(*performed, action)
+		if action.GetName() == "Spawn" {
+			for _, event := range sent {
+				stream <- event
+			}
+		}
+		return true
+	}
+}
+
+// actionNames returns the names of the actions.
+func actionNames(performed []actions.Action) []string {
+	var names []string
+	for _, action := range performed {
+		names = append(names, action.GetName())
+	}
+	return names
+}
+
+func TestSpawnWindowSpawnActions(t *testing.T) {
+	windows := []*models.Window{{ID: 2, AppID: "foot", WorkspaceID: 1}}
+	var workspaces []*models.Workspace
+	performed := mockNiri(t, &windows, &workspaces)
+	mockEventStream(t, performed,
+		// The other windows, and the already open windows, aren't the spawned window.
+		&events.WindowOpenedOrChanged{Window: &models.Window{ID: 4, AppID: "firefox"}},
+		&events.WindowOpenedOrChanged{Window: &models.Window{ID: 2, AppID: "foot"}},
+		&events.WindowOpenedOrChanged{Window: &models.Window{ID: 5, AppID: "foot"}},
+	)
+	command := models.SpawnOrFocusCommand{
+		Command:      []string{"foot"},
+		SpawnActions: map[string]json.RawMessage{"MoveWindowToFloating": json.RawMessage(`{}`)},
+	}
+
+	assert.NoError(t, spawnWindow("foot", windows, command))
+	assert.Equal(t, []string{"Spawn", "MoveWindowToFloating"}, actionNames(*performed))
+	floating, ok := (*performed)[1].(*actions.MoveWindowToFloating)
+	if assert.True(t, ok) {
+		assert.Equal(t, uint64(5), floating.ID)
+	}
+}
+
+func TestSpawnWindowTimeout(t *testing.T) {
+	var windows []*models.Window
+	var workspaces []*models.Workspace
+	performed := mockNiri(t, &windows, &workspaces)
+	mockEventStream(t, performed, &events.WindowOpenedOrChanged{Window: &models.Window{ID: 4, AppID: "firefox"}})
+	command := models.SpawnOrFocusCommand{
+		Command:      []string{"foot"},
+		SpawnActions: map[string]json.RawMessage{"MoveWindowToFloating": json.RawMessage(`{}`)},
+		Timeout:      "10ms",
+	}
+
+	// The window never appears, so the spawn actions aren't performed.
+	err := spawnWindow("foot", windows, command)
+	assert.ErrorIs(t, err, events.ErrWaitTimeout)
+	assert.Equal(t, []string{"Spawn"}, actionNames(*performed))
+}
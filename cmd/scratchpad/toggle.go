@@ -0,0 +1,165 @@
+package scratchpad
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/internal/connection"
+	"github.com/soderluk/nirimgr/internal/state"
+	"github.com/soderluk/nirimgr/models"
+	"github.com/spf13/cobra"
+)
+
+var toggleCmd = &cobra.Command{
+	Use:   "toggle [name]",
+	Short: "Toggle the scratchpad, or a named scratchpad",
+	Long: `Without a name, hides the visible scratchpad windows if there are any, otherwise shows a window from the scratchpad.
+
+With a name, toggles the named scratchpad as configured in the "scratchpads" section of the config.json.
+If no window matches the scratchpad, the command is spawned and the new window is shown. If the window is hidden, it's moved to the focused
+workspace as a floating window. If the window is on the focused workspace, it's moved back to the scratchpad workspace.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true, // If there's an error during running the command, don't show usage.
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return toggle()
+		}
+		return toggleScratchpad(args[0])
+	},
+}
+
+func init() {
+	ScratchCmd.AddCommand(toggleCmd)
+}
+
+// toggle hides the visible scratchpad windows, or shows a window from the scratchpad if none are visible.
+func toggle() error {
+	s, windows, err := loadState()
+	if err != nil {
+		return err
+	}
+	visible, err := visibleScratchpadWindows(s, windows)
+	if err != nil {
+		return err
+	}
+	if len(visible) > 0 {
+		return hideScratchpad()
+	}
+	return showScratchpad(false)
+}
+
+// toggleScratchpad toggles the named scratchpad.
+//
+// Like i3's `scratchpad show` with criteria: spawn the window if it's missing, bring it to the focused
+// workspace if it's hidden, or send it back to the scratchpad workspace if it's currently visible.
+func toggleScratchpad(name string) error {
+	scratchpad, err := config.Config.Scratchpad(name)
+	if err != nil {
+		slog.Error("Could not get scratchpad", "error", err.Error())
+		return err
+	}
+
+	windows, err := listWindows()
+	if err != nil {
+		slog.Error("Could not list windows", "error", err.Error())
+		return errors.New("could not list windows")
+	}
+	rule := scratchpad.Rule()
+	matchingWindows := filterWindows(windows, func(w *models.Window) bool {
+		return rule.WindowMatches(*w)
+	})
+
+	if len(matchingWindows) == 0 {
+		return spawnScratchpad(name, scratchpad, windows)
+	}
+
+	return toggleScratchpadWindow(name, models.WindowSlice{Windows: matchingWindows}.SortByFocus().Windows, scratchpad.Actions)
+}
+
+// spawnScratchpad spawns the command of the named scratchpad, and shows the spawned window.
+//
+// If the scratchpad has no command, the spawnOrFocus command with the same name is used, including its
+// working directory and environment variables.
+func spawnScratchpad(name string, scratchpad models.Scratchpad, windows []*models.Window) error {
+	command := models.SpawnOrFocusCommand{Command: scratchpad.Command}
+	if len(command.Command) == 0 {
+		var err error
+		command, err = config.Config.SpawnOrFocus.Lookup(name)
+		if err != nil {
+			slog.Error("Could not get command", "error", err.Error())
+			return err
+		}
+	}
+	// In a dry-run the window never appears, so there's nothing to wait for.
+	if connection.DryRun != nil {
+		slog.Debug("No window matches the scratchpad, spawning command", "name", name, "cmd", command.Command)
+		return spawnCommand(command)
+	}
+
+	rule := scratchpad.Rule()
+	window, err := spawnAndWait(windows, command, func(w *models.Window) bool {
+		return rule.WindowMatches(*w)
+	})
+	if err != nil {
+		return err
+	}
+	workspace, err := getShowWorkspace()
+	if err != nil {
+		return err
+	}
+	slog.Debug("Showing spawned scratchpad window", "name", name, "window", window.ID)
+	showTrackedWindow(window, workspace, scratchpad.Actions)
+	return nil
+}
+
+// toggleScratchpadWindow hides the matching window on the focused workspace, or shows the first matching window.
+//
+// The extra actions are performed on the shown window after the showScratchpadActions.
+func toggleScratchpadWindow(name string, matchingWindows []*models.Window, extraActions map[string]json.RawMessage) error {
+	focusedWorkspace, err := getShowWorkspace()
+	if err != nil {
+		return err
+	}
+	// Prefer the window on the focused workspace, so we hide it. Otherwise show the first matching window.
+	window := matchingWindows[0]
+	for _, w := range matchingWindows {
+		if w.WorkspaceID == focusedWorkspace.ID {
+			window = w
+			break
+		}
+	}
+
+	if window.WorkspaceID == focusedWorkspace.ID {
+		scratchpadWorkspace, err := getWorkspace(config.Config.ScratchpadWorkspace)
+		if err != nil {
+			return err
+		}
+		s, err := state.Load()
+		if err != nil {
+			slog.Error("Could not load state, not restoring the window geometry", "error", err.Error())
+			s = &state.State{}
+		}
+		slog.Debug("Hiding scratchpad window", "name", name, "window", window.ID)
+		tracked, _ := s.ScratchpadWindow(window.ID)
+		restoreGeometry(window, tracked)
+		hideWindow(window, scratchpadWorkspace)
+		return nil
+	}
+
+	slog.Debug("Showing scratchpad window", "name", name, "window", window.ID)
+	showTrackedWindow(window, focusedWorkspace, extraActions)
+	return nil
+}
+
+// showTrackedWindow shows the window like showWindow, and tracks it as a scratchpad window.
+//
+// This way the original floating size and position are restored when the window is hidden again.
+func showTrackedWindow(window *models.Window, workspace *models.Workspace, extraActions map[string]json.RawMessage) {
+	showWindow(window, workspace, extraActions)
+	updateState(func(s *state.State) {
+		s.AddScratchpadWindow(state.NewScratchpadWindow(window))
+		s.TouchScratchpadWindow(window.ID)
+	})
+}
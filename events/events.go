@@ -6,8 +6,11 @@ package events
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/expr-lang/expr"
 	"github.com/soderluk/nirimgr/actions"
@@ -28,97 +31,218 @@ func Run() {
 		slog.Error("Could not get events", "error", err.Error())
 		panic(err)
 	}
-	existingWindows := make(map[uint64]*models.Window)
-	existingWorkspaces := make(map[uint64]*models.Workspace)
+	Handle(events)
+}
+
+// Handle matches the rules and performs the configured actions on the events, until the events channel is closed.
+//
+// When the channel is closed, the pending debounced and throttled events are handled right away, and Handle
+// returns when the steps running in the background are done.
+func Handle(events <-chan Event) {
+	// The windows and workspaces, to know what changed.
+	state := newMirror()
+	existingWindows, existingWorkspaces := state.windows, state.workspaces
 
 	// Any events we want to specifically listen to and perform actions on the event Window/Workspace/whatever.
 	listenToEvents := config.Config.Events
+	// The debounced and throttled events are handled in this loop too, when their duration is over.
+	limiter := newLimiter()
 
-	for event := range events {
+	for {
+		var event Event
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				limiter.flush(performEventActions)
+				runningSteps.Wait()
+				return
+			}
+			event = ev
+		case key := <-limiter.due:
+			limiter.fire(key, performEventActions)
+			continue
+		}
 		// These events are specific for the matching logic of nirimgr.
 		switch ev := event.(type) {
 		case *WindowsChanged:
 			slog.Debug("Handling event", "name", common.Repr(ev))
 			for _, win := range ev.Windows {
 				matchWindowAndPerformActions(win, existingWindows)
-				existingWindows[win.ID] = win
 			}
 		case *WindowOpenedOrChanged:
 			slog.Debug("Handling event", "name", common.Repr(ev))
 			matchWindowAndPerformActions(ev.Window, existingWindows)
-			existingWindows[ev.Window.ID] = ev.Window
 		case *WindowClosed:
 			slog.Debug("Handling event", "name", common.Repr(ev))
-			delete(existingWindows, ev.ID)
 		case *WorkspacesChanged:
 			slog.Debug("Handling event", "name", common.Repr(ev))
-			// Remove workspaces that are no longer present
-			newWorkspaceIDs := make(map[uint64]struct{})
-			for _, workspace := range ev.Workspaces {
-				newWorkspaceIDs[workspace.ID] = struct{}{}
-			}
-			for id := range existingWorkspaces {
-				if _, found := newWorkspaceIDs[id]; !found {
-					slog.Debug("Removing workspace from existing workspaces", "id", id)
-					delete(existingWorkspaces, id)
-				}
-			}
 			for _, workspace := range ev.Workspaces {
 				matchWorkspaceAndPerformActions(workspace, existingWorkspaces)
-				existingWorkspaces[workspace.ID] = workspace
 			}
+		case *WorkspaceActivated:
+			slog.Debug("Handling event", "name", common.Repr(ev))
+			moveStickyWindows(ev.ID, existingWindows, existingWorkspaces)
+			handleEvent(ev, listenToEvents, limiter, existingWindows, existingWorkspaces)
 		default:
 			// Any events we're not specifically listening to, let's check if there are any configured events.
 			if ev != nil {
-				// Handle the event if it exists in the map
-				if actionConfigs, exists := listenToEvents[ev.GetName()]; exists {
-					for actionName, actionConfig := range actionConfigs {
-						rawAction := map[string]json.RawMessage{
-							actionName: actionConfig.Params,
-						}
-						// Perform each defined action on the event.
-						for _, a := range ActionsFromRaw(rawAction) {
-							evaluationResult, err := EvaluateCondition(actionConfig.When, ev)
-							if err != nil {
-								slog.Error("Error in EvaluateCondition", slog.Any("error", err))
-							}
-							if evaluationResult {
-								possibleKeys := ev.GetPossibleKeys()
-								a = actions.HandleDynamicIDs(a, possibleKeys)
-								connection.PerformAction(a)
-							} else {
-								slog.Debug(
-									"Not performing action",
-									slog.String("name", actionName),
-									slog.Bool("EvaluateCondition", evaluationResult),
-								)
-							}
-						}
-					}
-				}
+				handleEvent(ev, listenToEvents, limiter, existingWindows, existingWorkspaces)
 			}
 		}
+		// The events synthesized from the state changes are handled after the niri event.
+		for _, ev := range state.update(event) {
+			slog.Debug("Handling synthesized event", "name", common.Repr(ev))
+			handleEvent(ev, listenToEvents, limiter, existingWindows, existingWorkspaces)
+		}
+	}
+}
+
+// handleEvent performs the actions configured for the event in the config file, debounced or throttled
+// if configured so.
+//
+// If the handler has matches or excludes, the event is handled only if the window or workspace it refers to matches.
+func handleEvent(ev Event, listenToEvents map[string]models.EventHandler, limiter *limiter, existingWindows map[uint64]*models.Window, existingWorkspaces map[uint64]*models.Workspace) {
+	// Handle the event if it exists in the map
+	handler, exists := listenToEvents[ev.GetName()]
+	if !exists {
+		return
+	}
+	if handler.Filtered() {
+		window, workspace := eventModels(ev, existingWindows, existingWorkspaces)
+		if !handler.Matches(window, workspace) {
+			slog.Debug("Event didn't match the handler", "name", ev.GetName())
+			return
+		}
+	}
+	limiter.handle(ev, handler, performEventActions)
+}
+
+// performEventActions performs the actions of the event handler.
+func performEventActions(ev Event, handler models.EventHandler) {
+	for actionName, actionConfig := range handler.Actions {
+		performConfiguredAction("event "+ev.GetName(), actionName, actionConfig, ev, ev.GetPossibleKeys())
+	}
+}
+
+// runningSteps are the steps running in the background, waited for when the events channel is closed.
+var runningSteps sync.WaitGroup
+
+// performConfiguredAction performs the configured action on the model, if its condition evaluates to true.
+//
+// The params are rendered with the model, and the action is targeted according to the configured target.
+// Without a target, the possible keys are set as the dynamic IDs. The "steps" action runs its steps in the background.
+// The source is the rule or event handler of the action, shown in the dry-run output.
+func performConfiguredAction(source string, actionName string, actionConfig models.ActionConfig, model any, possibleKeys models.PossibleKeys) {
+	// The steps can take a while, so don't block the other events.
+	if actionName == models.StepsAction {
+		model := copyModel(model)
+		runningSteps.Go(func() { RunSteps(source, actionConfig.Steps, model, possibleKeys) })
+		return
+	}
+	// Sleeping would block the other events, so it's only supported in the steps.
+	if actionName == SleepAction {
+		slog.Warn("Sleep is only supported in steps, not performing it", "source", source)
+		return
+	}
+	performConditionalAction(source, actionName, actionConfig, model, possibleKeys)
+}
+
+// SleepAction is the name of the nirimgr action that pauses the steps.
+const SleepAction = "Sleep"
+
+// performConditionalAction performs the configured action on the model, if its condition evaluates to true.
+//
+// Unlike performConfiguredAction, this blocks until the action is performed, so the steps use it for sleeping.
+func performConditionalAction(source string, actionName string, actionConfig models.ActionConfig, model any, possibleKeys models.PossibleKeys) {
+	// If we have a condition defined, evaluate it, and perform the action if it evaluates to true.
+	evaluationResult, err := EvaluateCondition(actionConfig.When, model)
+	if err != nil {
+		slog.Error("Error in EvaluateCondition", slog.Any("error", err))
+	}
+	origin := connection.Origin{Source: source, When: actionConfig.When, Result: evaluationResult}
+	if !evaluationResult {
+		slog.Debug("Not performing action", slog.String("name", actionName), slog.Bool("EvaluateCondition", evaluationResult))
+		connection.SkipAction(actionName, origin)
+		return
+	}
+
+	params, err := RenderParams(actionConfig.Params, model)
+	if err != nil {
+		slog.Error("Could not render action params", "name", actionName, "error", err.Error())
+		return
+	}
+	rawAction := map[string]json.RawMessage{
+		actionName: params,
+	}
+	for _, a := range ActionsFromRaw(rawAction) {
+		a, err := targetAction(a, actionConfig.Target, params, model, possibleKeys)
+		if err != nil {
+			slog.Error("Could not resolve the action target", "name", actionName, "target", actionConfig.Target, "error", err.Error())
+			continue
+		}
+		connection.PerformActionFrom(a, origin)
 	}
 }
 
+// moveStickyWindows moves the sticky floating windows to the activated workspace.
+//
+// Only the windows on the same output as the activated workspace are moved, and the focus stays where it is.
+// niri doesn't have sticky windows, so this is inspired by https://github.com/probeldev/niri-float-sticky
+func moveStickyWindows(workspaceID uint64, existingWindows map[uint64]*models.Window, existingWorkspaces map[uint64]*models.Workspace) {
+	for _, window := range stickyWindowsToMove(workspaceID, existingWindows, existingWorkspaces) {
+		slog.Debug("Moving sticky window to the activated workspace", "window", window.ID, "workspace", workspaceID)
+		connection.PerformActionFrom(actions.MoveWindowToWorkspace{
+			AName:     actions.AName{Name: "MoveWindowToWorkspace"},
+			WindowID:  window.ID,
+			Reference: actions.WorkspaceReferenceArg{ID: workspaceID},
+			Focus:     false,
+		}, connection.Origin{Source: "sticky window"})
+		window.WorkspaceID = workspaceID
+	}
+}
+
+// stickyWindowsToMove returns the sticky floating windows on the output of the workspace, which are not on the workspace.
+//
+// The windows hidden on the scratchpad workspace stay there, and nothing follows to the scratchpad workspace.
+// The windows are sorted by their ID, so they're moved in a stable order.
+func stickyWindowsToMove(workspaceID uint64, existingWindows map[uint64]*models.Window, existingWorkspaces map[uint64]*models.Workspace) []*models.Window {
+	scratchpadName := config.Config.ScratchpadWorkspace
+	if scratchpadName == "" {
+		scratchpadName = "scratchpad"
+	}
+	workspace, ok := existingWorkspaces[workspaceID]
+	if !ok || workspace.Name == scratchpadName {
+		return nil
+	}
+	var windows []*models.Window
+	for _, window := range existingWindows {
+		if !window.Sticky || !window.IsFloating || window.WorkspaceID == workspaceID {
+			continue
+		}
+		current, ok := existingWorkspaces[window.WorkspaceID]
+		if !ok || current.Output != workspace.Output || current.Name == scratchpadName {
+			continue
+		}
+		windows = append(windows, window)
+	}
+	return models.WindowSlice{Windows: windows}.SortByID().Windows
+}
+
 // EventStream listens on the events in Niri event-stream.
 //
 // The function will use a goroutine to return the event models.
 // Inspiration from: https://github.com/probeldev/niri-float-sticky
 func EventStream() (<-chan Event, error) {
+	lines, err := RawEventStream()
+	if err != nil {
+		return nil, err
+	}
 	stream := make(chan Event)
-	socket := connection.Socket()
 
 	go func() {
-		defer connection.PutSocket(socket)
-		defer socket.Close()
 		defer close(stream)
 
-		for line := range socket.Recv() {
-			if len(line) < 2 {
-				continue
-			}
-
+		for line := range lines {
 			var event map[string]json.RawMessage
 
 			if err := json.Unmarshal(line, &event); err != nil {
@@ -133,6 +257,27 @@ func EventStream() (<-chan Event, error) {
 		}
 	}()
 
+	return stream, nil
+}
+
+// RawEventStream returns the raw lines of the niri event stream, without the empty lines.
+func RawEventStream() (<-chan []byte, error) {
+	stream := make(chan []byte)
+	socket := connection.Socket()
+
+	go func() {
+		defer connection.PutSocket(socket)
+		defer socket.Close()
+		defer close(stream)
+
+		for line := range socket.Recv() {
+			if len(line) < 2 {
+				continue
+			}
+			stream <- line
+		}
+	}()
+
 	if err := socket.Send(fmt.Sprintf("\"%s\"", models.EventStream)); err != nil {
 		return nil, fmt.Errorf("error requesting event stream: %w", err)
 	}
@@ -140,6 +285,42 @@ func EventStream() (<-chan Event, error) {
 	return stream, nil
 }
 
+// ErrWaitTimeout is returned by WaitForWindow when no matching window appeared in time.
+var ErrWaitTimeout = errors.New("timed out waiting for window")
+
+// WaitForWindow reads the events until a window matching the given function is opened or changed.
+//
+// The match function is called for every window in the WindowsChanged and WindowOpenedOrChanged events,
+// so it also catches windows that set their title or app-id late. Returns ErrWaitTimeout if no window
+// matched within the timeout.
+func WaitForWindow(stream <-chan Event, timeout time.Duration, match func(*models.Window) bool) (*models.Window, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-stream:
+			if !ok {
+				return nil, errors.New("event stream closed")
+			}
+			var windows []*models.Window
+			switch ev := event.(type) {
+			case *WindowsChanged:
+				windows = ev.Windows
+			case *WindowOpenedOrChanged:
+				windows = []*models.Window{ev.Window}
+			}
+			for _, window := range windows {
+				if window != nil && match(window) {
+					return window, nil
+				}
+			}
+		case <-timer.C:
+			return nil, ErrWaitTimeout
+		}
+	}
+}
+
 // ParseEvent parses the given event into it's struct.
 //
 // Returns the name, model, error. The name is the name of the event, model is the populated struct.
@@ -164,12 +345,17 @@ func matchWindowAndPerformActions(window *models.Window, existingWindows map[uin
 	window.Matched = false
 	if existing, ok := existingWindows[window.ID]; ok {
 		window.Matched = existing.Matched
+		window.Sticky = existing.Sticky
 	}
 
 	matchedBefore := window.Matched
+	if Trace != nil {
+		_, _ = fmt.Fprint(Trace, ExplainWindow(window, &matchedBefore))
+	}
 	window.Matched = false
 	var actionConfigs map[string]models.ActionConfig
-	for _, r := range config.Config.GetRules() {
+	var source string
+	for i, r := range config.Config.GetRules() {
 		if r.Type != "window" && r.Type != "" {
 			continue
 		}
@@ -178,34 +364,43 @@ func matchWindowAndPerformActions(window *models.Window, existingWindows map[uin
 			if len(r.Actions) > 0 {
 				actionConfigs = r.Actions
 			}
+			source = ruleSource(i, r.Type)
 			break
 		}
 	}
 	if window.Matched && !matchedBefore {
 		for actionName, actionConfig := range actionConfigs {
-			rawAction := map[string]json.RawMessage{
-				actionName: actionConfig.Params,
-			}
-			for _, a := range ActionsFromRaw(rawAction) {
-				// If we have a condition defined, evaluate it, and perform the action if it evaluates to true.
-				evaluationResult, err := EvaluateCondition(actionConfig.When, window)
-				if err != nil {
-					slog.Error("Error in EvaluateCondition", slog.Any("error", err))
-				}
-				if evaluationResult {
-					a = actions.HandleDynamicIDs(a, models.PossibleKeys{
-						ID:       window.ID,
-						WindowID: window.ID,
-					})
-					connection.PerformAction(a)
-				} else {
-					slog.Debug("Not doing action", slog.String("name", actionName), slog.Bool("EvaluateCondition", evaluationResult))
-				}
+			if actionName == StickyAction {
+				markSticky(window, actionConfig)
+				continue
 			}
+			performConfiguredAction(source, actionName, actionConfig, window, models.PossibleKeys{
+				ID:       window.ID,
+				WindowID: window.ID,
+			})
 		}
 	}
 }
 
+// StickyAction is the nirimgr action that marks the matched window sticky.
+//
+// It's not a niri action, so it's handled by nirimgr instead of being sent to niri.
+const StickyAction = "Sticky"
+
+// markSticky marks the window sticky, if the condition of the action evaluates to true.
+func markSticky(window *models.Window, actionConfig models.ActionConfig) {
+	evaluationResult, err := EvaluateCondition(actionConfig.When, window)
+	if err != nil {
+		slog.Error("Error in EvaluateCondition", slog.Any("error", err))
+	}
+	if !evaluationResult {
+		slog.Debug("Not doing action", slog.String("name", StickyAction), slog.Bool("EvaluateCondition", evaluationResult))
+		return
+	}
+	slog.Debug("Marking window sticky", "window", window.ID)
+	window.Sticky = true
+}
+
 // matchWorkspaceAndPerformActions updates the workspace struct if it matches the rule as configured in the config file.
 //
 // If the matching workspace has any defined actions in the config, run them sequentially on the matched workspace.
@@ -215,10 +410,14 @@ func matchWorkspaceAndPerformActions(workspace *models.Workspace, existingWorksp
 		workspace.Matched = existing.Matched
 	}
 	matchedBefore := workspace.Matched
+	if Trace != nil {
+		_, _ = fmt.Fprint(Trace, ExplainWorkspace(workspace, &matchedBefore))
+	}
 
 	workspace.Matched = false
 	var actionConfigs map[string]models.ActionConfig
-	for _, r := range config.Config.GetRules() {
+	var source string
+	for i, r := range config.Config.GetRules() {
 		if r.Type != "workspace" {
 			continue
 		}
@@ -227,43 +426,33 @@ func matchWorkspaceAndPerformActions(workspace *models.Workspace, existingWorksp
 			if len(r.Actions) > 0 {
 				actionConfigs = r.Actions
 			}
+			source = ruleSource(i, r.Type)
 			break
 		}
 	}
 	if workspace.Matched && !matchedBefore {
 		for actionName, actionConfig := range actionConfigs {
-			rawAction := map[string]json.RawMessage{
-				actionName: actionConfig.Params,
-			}
-			for _, a := range ActionsFromRaw(rawAction) {
-				// If we have a condition defined, evaluate it, and perform the action if it evaluates to true.
-				evaluationResult, err := EvaluateCondition(actionConfig.When, workspace)
-				if err != nil {
-					slog.Error("Error in EvaluateCondition", slog.Any("error", err))
-				}
-				if evaluationResult {
-					a = actions.HandleDynamicIDs(a, models.PossibleKeys{
-						ID:             workspace.ID,
-						ActiveWindowID: workspace.ActiveWindowID,
-						Reference: models.ReferenceKeys{
-							ID:    workspace.ID,
-							Index: workspace.Idx,
-							Name:  workspace.Name,
-						},
-					})
-					connection.PerformAction(a)
-				} else {
-					slog.Debug(
-						"Not performing action",
-						slog.String("name", actionName),
-						slog.Bool("EvaluateCondition", evaluationResult),
-					)
-				}
-			}
+			performConfiguredAction(source, actionName, actionConfig, workspace, models.PossibleKeys{
+				ID:             workspace.ID,
+				ActiveWindowID: workspace.ActiveWindowID,
+				Reference: models.ReferenceKeys{
+					ID:    workspace.ID,
+					Index: workspace.Idx,
+					Name:  workspace.Name,
+				},
+			})
 		}
 	}
 }
 
+// ruleSource returns the rule with the given index in the configured rules, as shown in the dry-run output.
+func ruleSource(index int, ruleType string) string {
+	if ruleType == "" {
+		ruleType = "window"
+	}
+	return fmt.Sprintf("rule %d (%s)", index+1, ruleType)
+}
+
 // ActionsFromRaw converts the raw actions from the config into a list of Action structs.
 func ActionsFromRaw(rawActions map[string]json.RawMessage) []actions.Action {
 	return actions.ParseRawActions(rawActions)
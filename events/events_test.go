@@ -1,11 +1,17 @@
 package events
 
 import (
+	"bytes"
 	"encoding/json"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/nalgeon/be"
+	"github.com/soderluk/nirimgr/actions"
 	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/internal/connection"
 	"github.com/soderluk/nirimgr/models"
 )
 
@@ -130,3 +136,748 @@ func TestEvaluateCondition(t *testing.T) {
 		be.Err(t, err)
 	})
 }
+
+func TestWaitForWindow(t *testing.T) {
+	matchTerm := func(w *models.Window) bool { return w.AppID == "special-term" }
+
+	t.Run("late app id", func(t *testing.T) {
+		stream := make(chan Event, 4)
+		stream <- &WindowsChanged{EName: EName{"WindowsChanged"}, Windows: []*models.Window{{ID: 1, AppID: "firefox"}}}
+		stream <- &WindowClosed{EName: EName{"WindowClosed"}, ID: 1}
+		stream <- &WindowOpenedOrChanged{EName: EName{"WindowOpenedOrChanged"}, Window: &models.Window{ID: 2}}
+		stream <- &WindowOpenedOrChanged{EName: EName{"WindowOpenedOrChanged"}, Window: &models.Window{ID: 2, AppID: "special-term"}}
+
+		window, err := WaitForWindow(stream, time.Second, matchTerm)
+		be.Err(t, err, nil)
+		be.Equal(t, window.ID, uint64(2))
+	})
+	t.Run("timeout", func(t *testing.T) {
+		stream := make(chan Event)
+		_, err := WaitForWindow(stream, 10*time.Millisecond, matchTerm)
+		be.Err(t, err, ErrWaitTimeout)
+	})
+	t.Run("closed stream", func(t *testing.T) {
+		stream := make(chan Event)
+		close(stream)
+		_, err := WaitForWindow(stream, time.Second, matchTerm)
+		be.Err(t, err)
+	})
+}
+
+func TestStickyWindows(t *testing.T) {
+	config.Config = &models.Config{
+		Rules: []models.Rule{
+			{
+				Match: []models.Match{{AppID: "pip"}},
+				Actions: map[string]models.ActionConfig{
+					StickyAction: {When: "model.IsFloating"},
+				},
+			},
+		},
+	}
+
+	existingWindows := map[uint64]*models.Window{}
+	for _, window := range []*models.Window{
+		{ID: 1, AppID: "pip", WorkspaceID: 1, IsFloating: true},
+		{ID: 2, AppID: "pip", WorkspaceID: 3, IsFloating: true},
+		{ID: 3, AppID: "pip", WorkspaceID: 1},
+		{ID: 4, AppID: "other", WorkspaceID: 1, IsFloating: true},
+		{ID: 5, AppID: "pip", WorkspaceID: 4, IsFloating: true},
+	} {
+		matchWindowAndPerformActions(window, existingWindows)
+		existingWindows[window.ID] = window
+	}
+	be.True(t, existingWindows[1].Sticky)
+	be.True(t, !existingWindows[3].Sticky)
+	be.True(t, !existingWindows[4].Sticky)
+
+	// The window stays sticky when it changes.
+	changed := &models.Window{ID: 1, AppID: "pip", Title: "changed", WorkspaceID: 1, IsFloating: true}
+	matchWindowAndPerformActions(changed, existingWindows)
+	be.True(t, changed.Sticky)
+
+	existingWorkspaces := map[uint64]*models.Workspace{
+		1: {ID: 1, Output: "DP-1"},
+		2: {ID: 2, Output: "DP-1"},
+		3: {ID: 3, Output: "HDMI-A-1"},
+		4: {ID: 4, Output: "DP-1", Name: "scratchpad"},
+	}
+	var ids []uint64
+	for _, window := range stickyWindowsToMove(2, existingWindows, existingWorkspaces) {
+		ids = append(ids, window.ID)
+	}
+	// Only the sticky floating window on the same output follows, the scratchpad window stays hidden.
+	be.Equal(t, ids, []uint64{1})
+	be.Equal(t, len(stickyWindowsToMove(4, existingWindows, existingWorkspaces)), 0)
+	be.Equal(t, len(stickyWindowsToMove(9, existingWindows, existingWorkspaces)), 0)
+}
+
+func TestRenderParams(t *testing.T) {
+	window := &models.Window{ID: 12, AppID: "firefox", Title: "Docs"}
+
+	tests := []struct {
+		params string
+		want   string
+	}{
+		// No templates keeps the params as they are.
+		{`{"ID": 1,  "focus": true}`, `{"ID": 1,  "focus": true}`},
+		{`{"name": "${model.AppID}"}`, `{"name":"firefox"}`},
+		// A single template keeps the type of the result.
+		{`{"id": "${model.ID}"}`, `{"id":12}`},
+		{`{"command": ["echo", "${model.Title} (${model.ID})", "${upper(model.AppID)}"]}`, `{"command":["echo","Docs (12)","FIREFOX"]}`},
+		{`{"name": "${ {'a': model.AppID}.a }"}`, `{"name":"firefox"}`},
+		{`{"name": "$${model.ID}", "big": 18446744073709551615, "x": "${model.AppID + '}'}"}`, `{"big":18446744073709551615,"name":"${model.ID}","x":"firefox}"}`},
+		// The expressions without the template roots aren't templates, e.g. in the shell commands.
+		{`{"command": ["sh", "-c", "notify-send ${HOME} ${USER:-me} ${'}'} ${"]}`, `{"command":["sh","-c","notify-send ${HOME} ${USER:-me} ${'}'} ${"]}`},
+		{`{"env": {"WIN": "${NIRIMGR_WINDOW_ID}-${model.ID}"}}`, `{"env":{"WIN":"${NIRIMGR_WINDOW_ID}-12"}}`},
+	}
+	for _, tt := range tests {
+		got, err := RenderParams(json.RawMessage(tt.params), window)
+		be.Err(t, err, nil)
+		be.Equal(t, string(got), tt.want)
+	}
+
+	for _, params := range []string{`{"name": "${model.AppID"}`, `{"name": "${model.Missing}"}`, `{"name": "${model.}"}`} {
+		_, err := RenderParams(json.RawMessage(params), window)
+		be.Err(t, err)
+	}
+}
+
+func TestRenderParamsWindow(t *testing.T) {
+	mockNiri(t, func() []*models.Window {
+		return []*models.Window{{ID: 1, Title: "Docs", WorkspaceID: 2}}
+	}, func() []*models.Workspace {
+		return []*models.Workspace{{ID: 2, Name: "web", ActiveWindowID: 1}}
+	})
+
+	// The event only has the ID of the window, so the window and its workspace are looked up.
+	params := json.RawMessage(`{"title": "${window.Title}", "workspace": "${workspace.Name}"}`)
+	got, err := RenderParams(params, &WindowFocusChanged{EName: EName{Name: "WindowFocusChanged"}, ID: 1})
+	be.Err(t, err, nil)
+	be.Equal(t, string(got), `{"title":"Docs","workspace":"web"}`)
+
+	got, err = RenderParams(params, &models.Workspace{ID: 2, Name: "web", ActiveWindowID: 1})
+	be.Err(t, err, nil)
+	be.Equal(t, string(got), `{"title":"Docs","workspace":"web"}`)
+
+	// The window is gone, e.g. no window is focused.
+	got, err = RenderParams(json.RawMessage(`{"title": "${window?.Title ?? 'none'}"}`), &WindowFocusChanged{EName: EName{Name: "WindowFocusChanged"}})
+	be.Err(t, err, nil)
+	be.Equal(t, string(got), `{"title":"none"}`)
+
+	// niri isn't asked for the windows if the template doesn't use them.
+	mockNiri(t, func() []*models.Window {
+		t.Fatal("windows requested for a template without the window")
+		return nil
+	}, nil)
+	got, err = RenderParams(json.RawMessage(`{"id": "${model.ID}"}`), &models.Window{ID: 4})
+	be.Err(t, err, nil)
+	be.Equal(t, string(got), `{"id":4}`)
+}
+
+func TestResolveTarget(t *testing.T) {
+	window := &models.Window{ID: 3, WorkspaceID: 2}
+	workspace := &models.Workspace{ID: 2, ActiveWindowID: 4}
+
+	tests := []struct {
+		target      string
+		model       any
+		windowID    uint64
+		workspaceID uint64
+	}{
+		{models.TargetSelf, window, 3, 2},
+		{models.TargetSelf, workspace, 0, 2},
+		{models.TargetSelf, &WindowOpenedOrChanged{Window: window}, 3, 2},
+		{models.TargetSelf, &WindowUrgencyChanged{ID: 3}, 3, 0},
+		{models.TargetWorkspace, window, 0, 2},
+		{models.TargetActiveWindow, workspace, 4, 2},
+		{models.TargetActiveWindow, &WorkspaceActiveWindowChanged{WorkspaceID: 2, ActiveWindowID: 4}, 4, 2},
+	}
+	for _, tt := range tests {
+		windowID, workspaceID, err := resolveTarget(tt.target, tt.model)
+		be.Err(t, err, nil)
+		be.Equal(t, windowID, tt.windowID)
+		be.Equal(t, workspaceID, tt.workspaceID)
+	}
+
+	_, _, err := resolveTarget("elsewhere", window)
+	be.Err(t, err)
+	// The window closed event has no workspace.
+	_, _, err = resolveTarget(models.TargetWorkspace, &WindowClosed{ID: 3})
+	be.Err(t, err)
+}
+
+func TestTargetAction(t *testing.T) {
+	params := json.RawMessage(`{}`)
+	keys := models.PossibleKeys{ID: 3, WindowID: 3}
+
+	// Without a target, the dynamic IDs are used.
+	a, err := targetAction(actions.FromRegistry("FocusWindow", params), "", params, &models.Window{ID: 3}, keys)
+	be.Err(t, err, nil)
+	be.Equal(t, a.(*actions.FocusWindow).ID, uint64(3))
+
+	// With none, the action is left as configured.
+	a, err = targetAction(actions.FromRegistry("FocusWindow", params), models.TargetNone, params, &models.Window{ID: 3}, keys)
+	be.Err(t, err, nil)
+	be.Equal(t, a.(*actions.FocusWindow).ID, uint64(0))
+
+	a, err = targetAction(actions.FromRegistry("FocusWindow", params), models.TargetActiveWindow, params, &models.Workspace{ID: 1, ActiveWindowID: 8}, keys)
+	be.Err(t, err, nil)
+	be.Equal(t, a.(*actions.FocusWindow).ID, uint64(8))
+}
+
+func TestRunSteps(t *testing.T) {
+	var performed []string
+	actions.NirimgrActionRegistry["Record"] = func() actions.Action { return &recordAction{AName: actions.AName{Name: "Record"}} }
+	actions.RegisterNirimgrHandler("Record", func(a actions.Action) error {
+		performed = append(performed, a.(*recordAction).Value)
+		return nil
+	})
+	defer delete(actions.NirimgrActionRegistry, "Record")
+
+	var actionConfig models.ActionConfig
+	err := json.Unmarshal([]byte(`[
+		{"Record": {"value": "first"}},
+		{"delay": "1ms", "Record": {"value": "${model.Field}"}},
+		{"when": "model.Field == 'other'", "Record": {"value": "skipped"}, "else": [{"Record": {"value": "else"}}]},
+		{"when": "model.Field == 'dummy'", "then": [{"Record": {"value": "then"}}]},
+		{"Record": {"value": "retry"}, "retry": {"until": "model.Field == 'other'", "attempts": 3, "interval": "1ms"}},
+		{"Record": {"value": "last"}}
+	]`), &actionConfig)
+	be.Err(t, err, nil)
+	mockNiri(t, func() []*models.Window { return nil }, nil)
+
+	RunSteps("", actionConfig.Steps, &DummyEvent{EName: EName{Name: "DummyEvent"}, Field: "dummy"}, models.PossibleKeys{})
+	be.Equal(t, performed, []string{"first", "dummy", "else", "then", "retry", "retry", "retry", "last"})
+}
+
+func TestRunStepsEvent(t *testing.T) {
+	var performed []string
+	actions.NirimgrActionRegistry["Record"] = func() actions.Action { return &recordAction{AName: actions.AName{Name: "Record"}} }
+	actions.RegisterNirimgrHandler("Record", func(a actions.Action) error {
+		performed = append(performed, a.(*recordAction).Value)
+		return nil
+	})
+	defer delete(actions.NirimgrActionRegistry, "Record")
+
+	var actionConfig models.ActionConfig
+	err := json.Unmarshal([]byte(`[
+		{"Record": {"value": "${model.AppID}"}, "retry": {"until": "model.IsFloating", "attempts": 5, "interval": "1ms"}},
+		{"when": "model.IsFloating", "Record": {"value": "floating"}}
+	]`), &actionConfig)
+	be.Err(t, err, nil)
+	// The window becomes floating on the third refresh, i.e. after the second attempt.
+	refreshes := 0
+	mockNiri(t, func() []*models.Window {
+		refreshes++
+		return []*models.Window{{ID: 4, AppID: "bitwarden", IsFloating: refreshes >= 3}}
+	}, nil)
+
+	// The steps of the configured events see the current state of the window the event refers to.
+	ev := &WindowUrgencyChanged{EName: EName{Name: "WindowUrgencyChanged"}, ID: 4, Urgent: true}
+	RunSteps("", actionConfig.Steps, ev, ev.GetPossibleKeys())
+	be.Equal(t, performed, []string{"bitwarden", "bitwarden", "floating"})
+
+	// The steps stop if the window is closed meanwhile.
+	performed, refreshes = nil, 0
+	mockNiri(t, func() []*models.Window {
+		refreshes++
+		if refreshes > 1 {
+			return nil
+		}
+		return []*models.Window{{ID: 4, AppID: "bitwarden"}}
+	}, nil)
+	RunSteps("", actionConfig.Steps, ev, ev.GetPossibleKeys())
+	be.Equal(t, performed, []string{"bitwarden"})
+}
+
+func TestSleepOnlyInSteps(t *testing.T) {
+	var output bytes.Buffer
+	connection.DryRun = &output
+	defer func() { connection.DryRun = nil }()
+	mockNiri(t, func() []*models.Window { return []*models.Window{{ID: 4}} }, nil)
+
+	sleep := models.ActionConfig{Params: json.RawMessage(`{"duration": "1h"}`)}
+	window := &models.Window{ID: 4}
+
+	// Sleeping would block the events loop, so it's skipped outside the steps.
+	performConfiguredAction("rule 1 (window)", SleepAction, sleep, window, models.PossibleKeys{})
+	be.Equal(t, output.String(), "")
+
+	RunSteps("rule 1 (window)", []models.Step{{Actions: map[string]models.ActionConfig{SleepAction: sleep}}}, window, models.PossibleKeys{})
+	be.Equal(t, output.String(), "Sleep {\"duration\":\"1h\"} # rule 1 (window)\n")
+}
+
+// mockNiri makes the steps get the windows and workspaces from the given functions, instead of niri.
+func mockNiri(t *testing.T, windows func() []*models.Window, workspaces func() []*models.Workspace) {
+	t.Helper()
+	originalListWindows, originalListWorkspaces := listWindows, listWorkspaces
+	t.Cleanup(func() { listWindows, listWorkspaces = originalListWindows, originalListWorkspaces })
+	listWindows = func() ([]*models.Window, error) { return windows(), nil }
+	listWorkspaces = func() ([]*models.Workspace, error) {
+		if workspaces == nil {
+			return nil, nil
+		}
+		return workspaces(), nil
+	}
+}
+
+type recordAction struct {
+	actions.AName
+	Value string `json:"value"`
+}
+
+func TestLimiter(t *testing.T) {
+	var handled []string
+	run := func(ev Event, handler models.EventHandler) {
+		handled = append(handled, ev.(*DummyEvent).Field)
+	}
+	event := func(field string) Event {
+		return &DummyEvent{EName: EName{Name: "DummyEvent"}, Field: field}
+	}
+	// wait handles the due events, until none arrive in a while.
+	wait := func(l *limiter) {
+		for {
+			select {
+			case key := <-l.due:
+				l.fire(key, run)
+			case <-time.After(50 * time.Millisecond):
+				return
+			}
+		}
+	}
+
+	t.Run("no limit", func(t *testing.T) {
+		handled = nil
+		l := newLimiter()
+		l.handle(event("a"), models.EventHandler{}, run)
+		l.handle(event("b"), models.EventHandler{}, run)
+		be.Equal(t, handled, []string{"a", "b"})
+	})
+	t.Run("debounce", func(t *testing.T) {
+		handled = nil
+		l := newLimiter()
+		handler := models.EventHandler{Debounce: "10ms"}
+		l.handle(event("a"), handler, run)
+		l.handle(event("b"), handler, run)
+		l.handle(event("c"), handler, run)
+		be.Equal(t, len(handled), 0)
+		wait(l)
+		be.Equal(t, handled, []string{"c"})
+		be.Equal(t, len(l.timers), 0)
+	})
+	t.Run("debounce after the timer fired", func(t *testing.T) {
+		handled = nil
+		l := newLimiter()
+		handler := models.EventHandler{Debounce: "10ms"}
+		l.handle(event("a"), handler, run)
+		// The timer is over, but its key is still waiting in the due channel when the next event arrives.
+		time.Sleep(20 * time.Millisecond)
+		l.handle(event("b"), handler, run)
+		l.fire(<-l.due, run)
+		be.Equal(t, len(handled), 0)
+		be.Equal(t, len(l.timers), 1)
+		wait(l)
+		be.Equal(t, handled, []string{"b"})
+		be.Equal(t, len(l.timers), 0)
+	})
+	t.Run("throttle", func(t *testing.T) {
+		handled = nil
+		l := newLimiter()
+		handler := models.EventHandler{Throttle: "10ms"}
+		l.handle(event("a"), handler, run)
+		l.handle(event("b"), handler, run)
+		l.handle(event("c"), handler, run)
+		be.Equal(t, handled, []string{"a"})
+		wait(l)
+		be.Equal(t, handled, []string{"a", "c"})
+		be.Equal(t, len(l.timers), 0)
+		l.handle(event("d"), handler, run)
+		be.Equal(t, handled, []string{"a", "c", "d"})
+	})
+}
+
+func TestLimitKey(t *testing.T) {
+	first := &WorkspaceActivated{EName: EName{Name: "WorkspaceActivated"}, ID: 1}
+	second := &WorkspaceActivated{EName: EName{Name: "WorkspaceActivated"}, ID: 2}
+	be.Equal(t, limitKey(first, models.EventHandler{}), limitKey(second, models.EventHandler{}))
+	perID := models.EventHandler{Per: models.PerID}
+	be.True(t, limitKey(first, perID) != limitKey(second, perID))
+	be.Equal(t, limitKey(first, perID), limitKey(first, perID))
+}
+
+func TestLimiterWindowLayoutsPerID(t *testing.T) {
+	var handled []uint64
+	run := func(ev Event, handler models.EventHandler) {
+		for _, change := range ev.(*WindowLayoutsChanged).Changes {
+			handled = append(handled, change.WindowID)
+		}
+	}
+	changed := func(ids ...uint64) Event {
+		ev := &WindowLayoutsChanged{EName: EName{Name: "WindowLayoutsChanged"}}
+		for _, id := range ids {
+			ev.Changes = append(ev.Changes, WindowLayoutChange{WindowID: id})
+		}
+		return ev
+	}
+
+	// The windows are debounced separately, so the change of window 2 doesn't drop the change of window 1.
+	l := newLimiter()
+	handler := models.EventHandler{Debounce: "10ms", Per: models.PerID}
+	l.handle(changed(1), handler, run)
+	l.handle(changed(2), handler, run)
+	l.handle(changed(1, 3), handler, run)
+	be.Equal(t, len(l.pending), 3)
+	for len(l.timers) > 0 {
+		l.fire(<-l.due, run)
+	}
+	slices.Sort(handled)
+	be.Equal(t, handled, []uint64{1, 2, 3})
+	be.Equal(t, changed(4).GetPossibleKeys().WindowID, uint64(4))
+}
+
+// eventNames returns the names of the events.
+func eventNames(events []Event) []string {
+	var names []string
+	for _, ev := range events {
+		names = append(names, ev.GetName())
+	}
+	return names
+}
+
+func TestWindowEvents(t *testing.T) {
+	window := &models.Window{ID: 1, Title: "old", AppID: "foot", WorkspaceID: 1}
+
+	opened := windowEvents(nil, window)
+	be.Equal(t, eventNames(opened), []string{"WindowOpened"})
+	be.Equal(t, opened[0].(*WindowOpened).Window, window)
+	be.Equal(t, len(windowEvents(window, window)), 0)
+
+	changed := &models.Window{ID: 1, Title: "new", AppID: "kitty", WorkspaceID: 2, IsFloating: true}
+	events := windowEvents(window, changed)
+	be.Equal(t, eventNames(events), []string{"WindowTitleChanged", "WindowAppIDChanged", "WindowMovedToWorkspace", "WindowFloatingChanged"})
+	be.Equal(t, *events[0].(*WindowTitleChanged), WindowTitleChanged{EName: EName{Name: "WindowTitleChanged"}, ID: 1, Title: "new", OldTitle: "old"})
+	be.Equal(t, *events[2].(*WindowMovedToWorkspace), WindowMovedToWorkspace{EName: EName{Name: "WindowMovedToWorkspace"}, ID: 1, WorkspaceID: 2, OldWorkspaceID: 1})
+	be.Equal(t, events[2].GetPossibleKeys().WorkspaceID, uint64(2))
+	be.True(t, events[3].(*WindowFloatingChanged).IsFloating)
+}
+
+func TestWorkspaceEvents(t *testing.T) {
+	previous := map[uint64]*models.Workspace{
+		1: {ID: 1, Output: "eDP-1"},
+		2: {ID: 2, Output: "DP-1"},
+		3: {ID: 3, Output: "DP-1"},
+	}
+	workspaces := []*models.Workspace{
+		{ID: 1, Output: "eDP-1"},
+		{ID: 2, Output: "HDMI-A-1"},
+		{ID: 4, Output: "HDMI-A-1"},
+	}
+	events := workspaceEvents(previous, workspaces)
+	be.Equal(t, eventNames(events), []string{"WorkspaceMovedToOutput", "WorkspaceCreated", "WorkspaceRemoved", "OutputConnected", "OutputDisconnected"})
+	be.Equal(t, *events[0].(*WorkspaceMovedToOutput), WorkspaceMovedToOutput{EName: EName{Name: "WorkspaceMovedToOutput"}, ID: 2, Output: "HDMI-A-1", OldOutput: "DP-1"})
+	be.Equal(t, events[1].(*WorkspaceCreated).Workspace.ID, uint64(4))
+	be.Equal(t, events[2].(*WorkspaceRemoved).Workspace.ID, uint64(3))
+	be.Equal(t, events[3].(*OutputConnected).Name, "HDMI-A-1")
+	be.Equal(t, events[4].(*OutputDisconnected).Name, "DP-1")
+
+	be.Equal(t, len(workspaceEvents(previous, []*models.Workspace{previous[1], previous[2], previous[3]})), 0)
+}
+
+func TestEventModels(t *testing.T) {
+	existingWindows := map[uint64]*models.Window{1: {ID: 1, AppID: "firefox", WorkspaceID: 2}}
+	existingWorkspaces := map[uint64]*models.Workspace{2: {ID: 2, Name: "work"}, 3: {ID: 3, Name: "chat"}}
+
+	window, workspace := eventModels(&WindowFocusChanged{ID: 1}, existingWindows, existingWorkspaces)
+	be.Equal(t, window, existingWindows[1])
+	be.Equal(t, workspace, existingWorkspaces[2])
+
+	window, workspace = eventModels(&WorkspaceActiveWindowChanged{WorkspaceID: 3, ActiveWindowID: 1}, existingWindows, existingWorkspaces)
+	be.Equal(t, window, existingWindows[1])
+	be.Equal(t, workspace, existingWorkspaces[3])
+
+	removed := &models.Workspace{ID: 4, Name: "gone"}
+	window, workspace = eventModels(&WorkspaceRemoved{Workspace: removed}, existingWindows, existingWorkspaces)
+	be.True(t, window == nil)
+	be.Equal(t, workspace, removed)
+
+	window, workspace = eventModels(&WindowFocusChanged{ID: 9}, existingWindows, existingWorkspaces)
+	be.True(t, window == nil)
+	be.True(t, workspace == nil)
+}
+
+func TestHandleEventMatch(t *testing.T) {
+	var performed []string
+	actions.NirimgrActionRegistry["Record"] = func() actions.Action { return &recordAction{AName: actions.AName{Name: "Record"}} }
+	actions.RegisterNirimgrHandler("Record", func(a actions.Action) error {
+		performed = append(performed, a.(*recordAction).Value)
+		return nil
+	})
+	defer delete(actions.NirimgrActionRegistry, "Record")
+
+	var listenToEvents map[string]models.EventHandler
+	err := json.Unmarshal([]byte(`{
+		"WindowFocusChanged": {"match": [{"appId": "firefox"}], "Record": {"value": "window ${model.ID}"}}
+	}`), &listenToEvents)
+	be.Err(t, err, nil)
+	existingWindows := map[uint64]*models.Window{1: {ID: 1, AppID: "firefox"}, 2: {ID: 2, AppID: "foot"}}
+
+	for _, id := range []uint64{1, 2, 3} {
+		ev := &WindowFocusChanged{EName: EName{Name: "WindowFocusChanged"}, ID: id}
+		handleEvent(ev, listenToEvents, newLimiter(), existingWindows, map[uint64]*models.Workspace{})
+	}
+	be.Equal(t, performed, []string{"window 1"})
+}
+
+func TestReplay(t *testing.T) {
+	recording := `{"time":"2025-09-01T12:00:00Z","event":{"WindowFocusChanged":{"id":3}}}
+
+{"time":"2025-09-01T12:00:00.002Z","event":{"UnknownEvent":{}}}
+{"time":"2025-09-01T12:00:00.004Z","event":{"WindowUrgencyChanged":{"id":4,"urgent":true}}}
+`
+	recorded, err := ReadRecording(strings.NewReader(recording))
+	be.Err(t, err, nil)
+	be.Equal(t, len(recorded), 3)
+	be.Equal(t, string(recorded[0].Event), `{"WindowFocusChanged":{"id":3}}`)
+
+	_, err = ReadRecording(strings.NewReader("not json\n"))
+	be.Err(t, err)
+
+	start := time.Now()
+	var names []string
+	for ev := range Replay(recorded, 1) {
+		names = append(names, ev.GetName())
+	}
+	be.Equal(t, names, []string{"WindowFocusChanged", "WindowUrgencyChanged"})
+	be.True(t, time.Since(start) >= 4*time.Millisecond)
+}
+
+func TestHandleReplayDryRun(t *testing.T) {
+	var output bytes.Buffer
+	connection.DryRun = &output
+	defer func() { connection.DryRun = nil }()
+	origConfig := config.Config
+	defer func() { config.Config = origConfig }()
+	config.Config = &models.Config{}
+	err := json.Unmarshal([]byte(`{
+		"WindowFocusChanged": {"FocusWindow": {"when": "model.ID == 3"}},
+		"WindowUrgencyChanged": {"debounce": "1h", "Log": {"message": "urgent ${model.ID}"}}
+	}`), &config.Config.Events)
+	be.Err(t, err, nil)
+
+	recorded := []RecordedEvent{
+		{Event: json.RawMessage(`{"WindowFocusChanged":{"id":3}}`)},
+		{Event: json.RawMessage(`{"WindowFocusChanged":{"id":5}}`)},
+		{Event: json.RawMessage(`{"WindowUrgencyChanged":{"id":4,"urgent":true}}`)},
+	}
+	Handle(Replay(recorded, 0))
+	be.Equal(t, output.String(), `FocusWindow {"id":3} # event WindowFocusChanged, when "model.ID == 3" is true
+# skipped FocusWindow (event WindowFocusChanged, when "model.ID == 3" is false)
+Log {"id":4,"message":"urgent 4"} # event WindowUrgencyChanged
+`)
+}
+
+func TestHandleWaitsForSteps(t *testing.T) {
+	var output bytes.Buffer
+	connection.DryRun = &output
+	defer func() { connection.DryRun = nil }()
+	origConfig := config.Config
+	defer func() { config.Config = origConfig }()
+	config.Config = &models.Config{}
+	err := json.Unmarshal([]byte(`{
+		"WindowUrgencyChanged": {"steps": [{"delay": "20ms", "Log": {"message": "urgent ${model.ID}"}}]}
+	}`), &config.Config.Events)
+	be.Err(t, err, nil)
+	mockNiri(t, func() []*models.Window { return []*models.Window{{ID: 4}} }, nil)
+
+	// The replay ends right away, but the steps are still running.
+	Handle(Replay([]RecordedEvent{{Event: json.RawMessage(`{"WindowUrgencyChanged":{"id":4,"urgent":true}}`)}}, 0))
+	be.Equal(t, output.String(), "Log {\"id\":4,\"message\":\"urgent 4\"} # event WindowUrgencyChanged\n")
+}
+
+func TestMatchWindowDryRun(t *testing.T) {
+	var output bytes.Buffer
+	connection.DryRun = &output
+	defer func() { connection.DryRun = nil }()
+	origConfig := config.Config
+	defer func() { config.Config = origConfig }()
+	config.Config = &models.Config{}
+	err := json.Unmarshal([]byte(`{"rules": [
+		{"type": "workspace", "match": [{"name": "chat"}], "actions": {"FocusWorkspace": {}}},
+		{"match": [{"appId": "foot"}], "actions": {"MoveWindowToFloating": {}}}
+	]}`), config.Config)
+	be.Err(t, err, nil)
+
+	matchWindowAndPerformActions(&models.Window{ID: 7, AppID: "foot"}, map[uint64]*models.Window{})
+	be.Equal(t, output.String(), "MoveWindowToFloating {\"id\":7} # rule 2 (window)\n")
+}
+
+func TestMatchWindowShellCommand(t *testing.T) {
+	var output bytes.Buffer
+	connection.DryRun = &output
+	defer func() { connection.DryRun = nil }()
+	origConfig := config.Config
+	defer func() { config.Config = origConfig }()
+	config.Config = &models.Config{}
+	// The shell variables in the commands of the existing configs are passed through, not rendered as templates.
+	err := json.Unmarshal([]byte(`{"rules": [
+		{"match": [{"appId": "foot"}], "actions": {"Spawn": {"command": ["sh", "-c", "notify-send ${HOME}"]}}}
+	]}`), config.Config)
+	be.Err(t, err, nil)
+
+	matchWindowAndPerformActions(&models.Window{ID: 7, AppID: "foot"}, map[uint64]*models.Window{})
+	be.Equal(t, output.String(), "Spawn {\"command\":[\"sh\",\"-c\",\"notify-send ${HOME}\"]} # rule 1 (window)\n")
+}
+
+func TestTail(t *testing.T) {
+	stream := make(chan Event)
+	go func() {
+		defer close(stream)
+		stream <- &WorkspacesChanged{EName: EName{Name: "WorkspacesChanged"}, Workspaces: []*models.Workspace{{ID: 2, Name: "work", Output: "DP-1"}}}
+		stream <- &WindowsChanged{EName: EName{Name: "WindowsChanged"}, Windows: []*models.Window{
+			{ID: 1, AppID: "firefox", Title: "GitHub", WorkspaceID: 2},
+			{ID: 3, AppID: "foot", WorkspaceID: 2},
+		}}
+		stream <- &WindowFocusChanged{EName: EName{Name: "WindowFocusChanged"}, ID: 3}
+		stream <- &WindowFocusChanged{EName: EName{Name: "WindowFocusChanged"}, ID: 1}
+		stream <- &WindowOpenedOrChanged{EName: EName{Name: "WindowOpenedOrChanged"}, Window: &models.Window{ID: 1, AppID: "firefox", Title: "Issues", WorkspaceID: 2}}
+		stream <- &WindowClosed{EName: EName{Name: "WindowClosed"}, ID: 1}
+	}()
+
+	var tailed []TailedEvent
+	Tail(stream, TailFilter{Window: "model.AppID == 'firefox'"}, func(ev TailedEvent) {
+		tailed = append(tailed, ev)
+	})
+	be.Equal(t, len(tailed), 4)
+	be.Equal(t, tailed[0].Name, "WindowFocusChanged")
+	be.Equal(t, tailed[1].Name, "WindowOpenedOrChanged")
+	be.Equal(t, tailed[2].Name, "WindowTitleChanged")
+	be.True(t, tailed[2].Synthesized)
+	be.Equal(t, tailed[3].Name, "WindowClosed")
+	be.Equal(t, tailed[3].Window.Title, "Issues")
+
+	tailed[0].Time = time.Date(2025, 9, 1, 12, 0, 1, 123000000, time.UTC)
+	be.Equal(t, tailed[0].String(), `12:00:01.123 WindowFocusChanged {"id":1} window 1 firefox "GitHub" on workspace 2 "work" (DP-1)`)
+}
+
+func TestTailFilter(t *testing.T) {
+	tailed := TailedEvent{
+		Name:      "WorkspaceActivated",
+		Event:     &WorkspaceActivated{EName: EName{Name: "WorkspaceActivated"}, ID: 2, Focused: true},
+		Workspace: &models.Workspace{ID: 2, Name: "work"},
+	}
+	tests := []struct {
+		filter TailFilter
+		want   bool
+	}{
+		{TailFilter{}, true},
+		{TailFilter{Names: []string{"WorkspaceActivated", "WindowFocusChanged"}}, true},
+		{TailFilter{Names: []string{"WindowFocusChanged"}}, false},
+		{TailFilter{Workspace: "model.Name == 'work'", When: "model.Focused"}, true},
+		{TailFilter{When: "!model.Focused"}, false},
+		{TailFilter{Window: "true"}, false},
+		// The window and workspace can be selected by their ID too.
+		{TailFilter{Workspace: "2"}, true},
+		{TailFilter{Workspace: "3"}, false},
+		{TailFilter{Workspace: "focused"}, false},
+	}
+	for _, tt := range tests {
+		be.Err(t, tt.filter.Validate(), nil)
+		got, err := tt.filter.Matches(tailed)
+		be.Err(t, err, nil)
+		be.Equal(t, got, tt.want)
+	}
+	_, err := TailFilter{When: "model.Unknown +"}.Matches(tailed)
+	be.Err(t, err)
+
+	// The typos are found before any events arrive.
+	for _, filter := range []TailFilter{
+		{When: "model.Unknown +"},
+		{When: "modl.Focused"},
+		{Window: "model.AppId == 'firefox'"},
+		{Workspace: "model.Name"},
+	} {
+		be.Err(t, filter.Validate())
+	}
+}
+
+func TestExplainWindow(t *testing.T) {
+	origConfig := config.Config
+	defer func() { config.Config = origConfig }()
+	config.Config = &models.Config{}
+	err := json.Unmarshal([]byte(`{"rules": [
+		{"type": "workspace", "match": [{"name": "chat"}], "actions": {"FocusWorkspace": {}}},
+		{"match": [{"appId": "firefox"}]},
+		{"match": [{"appId": "^foot$"}], "exclude": [{"title": "vim"}], "actions": {
+			"MoveWindowToFloating": {"when": "model.IsFloating"}, "CenterWindow": {}
+		}},
+		{"match": [{"title": "shell"}], "actions": {"CenterWindow": {}}}
+	]}`), config.Config)
+	be.Err(t, err, nil)
+
+	matchedBefore := false
+	explanation := ExplainWindow(&models.Window{ID: 7, AppID: "foot", Title: "shell", WorkspaceID: 2}, &matchedBefore)
+	be.True(t, explanation.Matched())
+	be.Equal(t, len(explanation.Rules), 4)
+	be.True(t, !explanation.Rules[0].Result.TypeMatches)
+	be.True(t, !explanation.Rules[1].Result.Matched)
+	be.True(t, explanation.Rules[2].Result.Matched && !explanation.Rules[2].Shadowed)
+	be.True(t, explanation.Rules[3].Result.Matched && explanation.Rules[3].Shadowed)
+	be.Equal(t, explanation.Rules[2].Actions, []ActionExplanation{
+		{Name: "CenterWindow", Result: true},
+		{Name: "MoveWindowToFloating", When: "model.IsFloating"},
+	})
+	be.Equal(t, explanation.Rules[3].Actions, nil)
+	be.Equal(t, explanation.String(), `window 7 foot "shell" on workspace 2
+rule 1 (workspace): skipped, not a window rule
+rule 2 (window): not matched
+  match 1: not matched
+    appId "firefox" against "foot": not matched
+rule 3 (window): matched
+  match 1: matched
+    appId "^foot$" against "foot": matched
+  exclude 1: not matched
+    title "vim" against "shell": not matched
+  action CenterWindow: performed
+  action MoveWindowToFloating: not performed, when "model.IsFloating" is false
+rule 4 (window): matched, but an earlier rule matched first
+  match 1: matched
+    title "shell" against "shell": matched
+the window didn't match before, so the actions are performed
+`)
+
+	// The events daemon traces the explanation, and doesn't perform the actions of a window that matched before.
+	var trace, output bytes.Buffer
+	Trace = &trace
+	defer func() { Trace = nil }()
+	connection.DryRun = &output
+	defer func() { connection.DryRun = nil }()
+	window := &models.Window{ID: 8, AppID: "foot", Title: "vim"}
+	matchWindowAndPerformActions(window, map[uint64]*models.Window{8: {ID: 8, Matched: true}})
+	be.True(t, !window.Matched)
+	be.True(t, strings.Contains(trace.String(), "rule 3 (window): not matched\n"))
+	be.True(t, strings.Contains(trace.String(), "  exclude 1: matched, so the rule is excluded\n"))
+	be.True(t, !strings.Contains(trace.String(), "matched before"))
+	be.Equal(t, output.String(), "")
+}
+
+func TestExplainWorkspace(t *testing.T) {
+	origConfig := config.Config
+	defer func() { config.Config = origConfig }()
+	config.Config = &models.Config{}
+	err := json.Unmarshal([]byte(`{"rules": [
+		{"match": [{"appId": "foot"}]},
+		{"type": "workspace", "match": [{"output": "eDP-1"}], "actions": {"SetWorkspaceName": {"name": "chat"}}}
+	]}`), config.Config)
+	be.Err(t, err, nil)
+
+	explanation := ExplainWorkspace(&models.Workspace{ID: 3, Name: "chat", Output: "eDP-1"}, nil)
+	be.Equal(t, explanation.String(), `workspace 3 "chat" on eDP-1
+rule 1 (window): skipped, not a workspace rule
+rule 2 (workspace): matched
+  match 1: matched
+    output "eDP-1" against "eDP-1": matched
+  action SetWorkspaceName: performed
+the actions are performed only when the workspace first matches, which only the events daemon knows
+`)
+}
@@ -0,0 +1,257 @@
+package events
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/internal/connection"
+	"github.com/soderluk/nirimgr/models"
+)
+
+// Trace is where the events daemon writes the explanation of the rules for every window and workspace it matches, if set.
+var Trace io.Writer
+
+// ActionExplanation tells if an action of the matched rule is performed, by its condition.
+type ActionExplanation struct {
+	// Name is the name of the action.
+	Name string
+	// When is the condition of the action, if any.
+	When string
+	// Result is what the condition evaluated to.
+	Result bool
+	// Error is the error in evaluating the condition, if any.
+	Error string
+}
+
+// RuleExplanation tells how a rule applies to the window or workspace.
+type RuleExplanation struct {
+	// Index is the index of the rule in the configured rules.
+	Index int
+	// Rule is the rule.
+	Rule models.Rule
+	// Result tells how the rule was matched.
+	Result models.RuleResult
+	// Shadowed tells if an earlier rule matched, so the actions of this rule aren't performed.
+	Shadowed bool
+	// Actions contains the actions of the first matched rule, with the results of their conditions.
+	Actions []ActionExplanation
+}
+
+// Explanation tells why the rules did or didn't match a window or workspace, and which of their actions are performed.
+type Explanation struct {
+	// Window is the explained window, if any.
+	Window *models.Window
+	// Workspace is the explained workspace, if any.
+	Workspace *models.Workspace
+	// MatchedBefore tells if the window or workspace matched a rule before. The actions are performed only when it
+	// first matches. Nil if it's not known, i.e. outside the events daemon.
+	MatchedBefore *bool
+	// Rules contains the explanations of all the configured rules, in order.
+	Rules []RuleExplanation
+}
+
+// ExplainWindow explains how the configured rules apply to the window.
+func ExplainWindow(window *models.Window, matchedBefore *bool) Explanation {
+	return explain(Explanation{Window: window, MatchedBefore: matchedBefore}, window, func(r models.Rule) models.RuleResult {
+		return r.ExplainWindow(*window)
+	})
+}
+
+// ExplainWorkspace explains how the configured rules apply to the workspace.
+func ExplainWorkspace(workspace *models.Workspace, matchedBefore *bool) Explanation {
+	return explain(Explanation{Workspace: workspace, MatchedBefore: matchedBefore}, workspace, func(r models.Rule) models.RuleResult {
+		return r.ExplainWorkspace(*workspace)
+	})
+}
+
+// explain explains all the configured rules with the explain function, like the events daemon matches them.
+//
+// The first matching rule wins, and the conditions of its actions are evaluated with the model.
+func explain(explanation Explanation, model any, explainRule func(models.Rule) models.RuleResult) Explanation {
+	matched := false
+	for i, r := range config.Config.GetRules() {
+		ruleExplanation := RuleExplanation{Index: i, Rule: r, Result: explainRule(r)}
+		if ruleExplanation.Result.Matched {
+			ruleExplanation.Shadowed = matched
+			if !matched {
+				ruleExplanation.Actions = explainActions(r.Actions, model)
+			}
+			matched = true
+		}
+		explanation.Rules = append(explanation.Rules, ruleExplanation)
+	}
+	return explanation
+}
+
+// explainActions evaluates the conditions of the actions with the model, ordered by the action name.
+func explainActions(actionConfigs map[string]models.ActionConfig, model any) []ActionExplanation {
+	var explanations []ActionExplanation
+	for _, name := range slices.Sorted(maps.Keys(actionConfigs)) {
+		actionConfig := actionConfigs[name]
+		explanation := ActionExplanation{Name: name, When: actionConfig.When}
+		result, err := EvaluateCondition(actionConfig.When, model)
+		if err != nil {
+			explanation.Error = err.Error()
+		}
+		explanation.Result = result
+		explanations = append(explanations, explanation)
+	}
+	return explanations
+}
+
+// Matched tells if any of the rules matched.
+func (e Explanation) Matched() bool {
+	return slices.ContainsFunc(e.Rules, func(r RuleExplanation) bool { return r.Result.Matched })
+}
+
+// String returns the explanation in a human-readable form, rule by rule.
+func (e Explanation) String() string {
+	var b strings.Builder
+	kind := "window"
+	if e.Window != nil {
+		fmt.Fprintf(&b, "window %d %v %q on workspace %d\n", e.Window.ID, e.Window.AppID, e.Window.Title, e.Window.WorkspaceID)
+	} else if e.Workspace != nil {
+		kind = "workspace"
+		fmt.Fprintf(&b, "workspace %d %q on %v\n", e.Workspace.ID, e.Workspace.Name, e.Workspace.Output)
+	}
+	if len(e.Rules) == 0 {
+		b.WriteString("no rules configured\n")
+	}
+	for _, r := range e.Rules {
+		source := ruleSource(r.Index, r.Rule.Type)
+		switch {
+		case !r.Result.TypeMatches:
+			fmt.Fprintf(&b, "%s: skipped, not a %s rule\n", source, kind)
+			continue
+		case r.Shadowed:
+			fmt.Fprintf(&b, "%s: matched, but an earlier rule matched first\n", source)
+		case r.Result.Matched && len(r.Rule.Match) == 0:
+			fmt.Fprintf(&b, "%s: matched, the rule has no matches\n", source)
+		case r.Result.Matched:
+			fmt.Fprintf(&b, "%s: matched\n", source)
+		default:
+			fmt.Fprintf(&b, "%s: not matched\n", source)
+		}
+		writeMatchResults(&b, "match", r.Result.Matches, kind)
+		writeMatchResults(&b, "exclude", r.Result.Excludes, kind)
+		for _, a := range r.Actions {
+			switch {
+			case a.Error != "":
+				fmt.Fprintf(&b, "  action %s: not performed, %s\n", a.Name, a.Error)
+			case a.When == "":
+				fmt.Fprintf(&b, "  action %s: performed\n", a.Name)
+			case a.Result:
+				fmt.Fprintf(&b, "  action %s: performed, when %q is true\n", a.Name, a.When)
+			default:
+				fmt.Fprintf(&b, "  action %s: not performed, when %q is false\n", a.Name, a.When)
+			}
+		}
+	}
+	switch {
+	case !e.Matched():
+	case e.MatchedBefore == nil:
+		fmt.Fprintf(&b, "the actions are performed only when the %s first matches, which only the events daemon knows\n", kind)
+	case *e.MatchedBefore:
+		fmt.Fprintf(&b, "the %s matched before, so the actions aren't performed again\n", kind)
+	default:
+		fmt.Fprintf(&b, "the %s didn't match before, so the actions are performed\n", kind)
+	}
+	return b.String()
+}
+
+// writeMatchResults writes the results of the matches or excludes, field by field.
+func writeMatchResults(b *strings.Builder, label string, results []models.MatchResult, kind string) {
+	for i, result := range results {
+		state := "not matched"
+		if result.Matched {
+			state = "matched"
+			if label == "exclude" {
+				state = "matched, so the rule is excluded"
+			}
+		}
+		if len(result.Fields) == 0 {
+			fields := "title or appId"
+			if kind == "workspace" {
+				fields = "name or output"
+			}
+			state += ", no " + fields + " given"
+		}
+		fmt.Fprintf(b, "  %s %d: %s\n", label, i+1, state)
+		for _, field := range result.Fields {
+			fieldState := "not matched"
+			switch {
+			case field.Error != "":
+				fieldState = "invalid pattern, " + field.Error
+			case field.Matched:
+				fieldState = "matched"
+			}
+			fmt.Fprintf(b, "    %s %q against %q: %s\n", field.Field, field.Pattern, field.Value, fieldState)
+		}
+	}
+}
+
+// FindWindow returns the window for the selector, which is "focused", the ID of the window, or a condition,
+// e.g. "model.AppID == 'firefox'". With a condition, the first matching window by ID is returned.
+func FindWindow(selector string) (*models.Window, error) {
+	windows, err := connection.ListWindows()
+	if err != nil {
+		return nil, errors.New("could not get windows")
+	}
+	for _, window := range (models.WindowSlice{Windows: windows}).SortByID().Windows {
+		matches, err := selectorMatches(selector, window, window.ID, window.IsFocused)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			return window, nil
+		}
+	}
+	return nil, fmt.Errorf("no window matches '%v'", selector)
+}
+
+// FindWorkspace returns the workspace for the selector, which is "focused", the ID of the workspace, or a condition,
+// e.g. "model.Name == 'work'". With a condition, the first matching workspace by ID is returned.
+func FindWorkspace(selector string) (*models.Workspace, error) {
+	workspaces, err := connection.ListWorkspaces()
+	if err != nil {
+		return nil, errors.New("could not get workspaces")
+	}
+	slices.SortFunc(workspaces, func(a, b *models.Workspace) int { return cmp.Compare(a.ID, b.ID) })
+	for _, workspace := range workspaces {
+		matches, err := selectorMatches(selector, workspace, workspace.ID, workspace.IsFocused)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			return workspace, nil
+		}
+	}
+	return nil, fmt.Errorf("no workspace matches '%v'", selector)
+}
+
+// isConditionSelector tells if the selector is a condition, instead of "focused" or an ID.
+func isConditionSelector(selector string) bool {
+	if selector == models.TargetFocused {
+		return false
+	}
+	_, err := strconv.ParseUint(selector, 10, 64)
+	return err != nil
+}
+
+// selectorMatches checks if the selector matches the window or workspace with the given ID and focus.
+func selectorMatches(selector string, model any, id uint64, focused bool) (bool, error) {
+	if selector == models.TargetFocused {
+		return focused, nil
+	}
+	if selectedID, err := strconv.ParseUint(selector, 10, 64); err == nil {
+		return id == selectedID, nil
+	}
+	return EvaluateCondition(strings.TrimSpace(selector), model)
+}
@@ -0,0 +1,146 @@
+package events
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/soderluk/nirimgr/models"
+)
+
+// limitedEvent is an event waiting for its debounce or throttle duration.
+type limitedEvent struct {
+	handler models.EventHandler
+	event   Event
+}
+
+// limiter debounces and throttles the event handlers of the events daemon.
+//
+// The timers only send the key of the due event to the due channel, so the handlers are still run
+// by the daemon's event loop, one at a time.
+type limiter struct {
+	due     chan dueEvent
+	timers  map[string]limitTimer
+	pending map[string]limitedEvent
+	// generation is the generation of the latest timer.
+	generation uint64
+}
+
+// limitTimer is the timer of a key, with its generation to tell it from the earlier timers of the key.
+type limitTimer struct {
+	timer      *time.Timer
+	generation uint64
+}
+
+// dueEvent tells that the timer of the given generation is over for the key.
+//
+// A timer that already sent to the due channel can't be stopped, so the due events of the replaced timers are ignored
+// by their generation.
+type dueEvent struct {
+	key        string
+	generation uint64
+}
+
+// newLimiter returns a limiter without any pending events.
+func newLimiter() *limiter {
+	return &limiter{
+		due:     make(chan dueEvent, 16),
+		timers:  make(map[string]limitTimer),
+		pending: make(map[string]limitedEvent),
+	}
+}
+
+// handle runs the handler on the event, or schedules it according to the handler's debounce or throttle.
+func (l *limiter) handle(ev Event, handler models.EventHandler, run func(Event, models.EventHandler)) {
+	duration, debounce, err := handler.Limit()
+	if err != nil || duration == 0 {
+		run(ev, handler)
+		return
+	}
+	// The layout changes of the windows are limited separately per ID, so a change of one window doesn't replace the others.
+	if changed, ok := ev.(*WindowLayoutsChanged); ok && handler.Per == models.PerID && len(changed.Changes) > 1 {
+		for _, change := range changed.Changes {
+			l.handle(&WindowLayoutsChanged{EName: changed.EName, Changes: []WindowLayoutChange{change}}, handler, run)
+		}
+		return
+	}
+	key := limitKey(ev, handler)
+	timer, waiting := l.timers[key]
+	switch {
+	case debounce && waiting:
+		// Wait for the duration again, with the latest event.
+		timer.timer.Stop()
+		l.pending[key] = limitedEvent{handler: handler, event: ev}
+		l.startTimer(key, duration)
+	case debounce:
+		l.pending[key] = limitedEvent{handler: handler, event: ev}
+		l.startTimer(key, duration)
+	case waiting:
+		// Throttled, handle the latest event when the duration is over.
+		l.pending[key] = limitedEvent{handler: handler, event: ev}
+	default:
+		run(ev, handler)
+		l.startTimer(key, duration)
+	}
+}
+
+// fire runs the pending event of the key, when its duration is over.
+//
+// The due events of the replaced or stopped timers are ignored.
+func (l *limiter) fire(due dueEvent, run func(Event, models.EventHandler)) {
+	key := due.key
+	if timer, ok := l.timers[key]; !ok || timer.generation != due.generation {
+		slog.Debug("Ignoring replaced limit timer", "key", key)
+		return
+	}
+	limited, ok := l.pending[key]
+	delete(l.pending, key)
+	if !ok {
+		// Nothing happened during the throttle duration, so the next event is handled right away.
+		delete(l.timers, key)
+		return
+	}
+	duration, debounce, _ := limited.handler.Limit()
+	if debounce {
+		delete(l.timers, key)
+	} else {
+		// Keep throttling after handling the trailing event.
+		l.startTimer(key, duration)
+	}
+	slog.Debug("Handling limited event", "key", key)
+	run(limited.event, limited.handler)
+}
+
+// flush stops the timers and runs the pending events right away, ordered by their keys.
+func (l *limiter) flush(run func(Event, models.EventHandler)) {
+	for _, timer := range l.timers {
+		timer.timer.Stop()
+	}
+	for _, key := range slices.Sorted(maps.Keys(l.pending)) {
+		limited := l.pending[key]
+		run(limited.event, limited.handler)
+	}
+	l.timers = make(map[string]limitTimer)
+	l.pending = make(map[string]limitedEvent)
+}
+
+// startTimer starts a new timer for the key, sending the key to the due channel after the duration.
+func (l *limiter) startTimer(key string, duration time.Duration) {
+	l.generation++
+	due := dueEvent{key: key, generation: l.generation}
+	l.timers[key] = limitTimer{
+		timer:      time.AfterFunc(duration, func() { l.due <- due }),
+		generation: due.generation,
+	}
+}
+
+// limitKey returns the key of the events that are debounced or throttled together.
+func limitKey(ev Event, handler models.EventHandler) string {
+	if handler.Per != models.PerID {
+		return ev.GetName()
+	}
+	windowID, workspaceID := modelIDs(ev)
+	return fmt.Sprintf("%s:%d:%d", ev.GetName(), windowID, workspaceID)
+}
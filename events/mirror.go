@@ -0,0 +1,64 @@
+package events
+
+import (
+	"log/slog"
+
+	"github.com/soderluk/nirimgr/models"
+)
+
+// mirror keeps track of the windows and workspaces from the events, like niri sees them.
+type mirror struct {
+	windows    map[uint64]*models.Window
+	workspaces map[uint64]*models.Workspace
+	// The first WindowsChanged and WorkspacesChanged events contain the initial state, so no events are
+	// synthesized from them.
+	windowsSynced, workspacesSynced bool
+}
+
+// newMirror returns a mirror without any windows or workspaces.
+func newMirror() *mirror {
+	return &mirror{
+		windows:    make(map[uint64]*models.Window),
+		workspaces: make(map[uint64]*models.Workspace),
+	}
+}
+
+// update applies the event to the windows and workspaces, and returns the events synthesized from the changes.
+func (m *mirror) update(event Event) []Event {
+	var synthesized []Event
+	switch ev := event.(type) {
+	case *WindowsChanged:
+		for _, win := range ev.Windows {
+			if m.windowsSynced {
+				synthesized = append(synthesized, windowEvents(m.windows[win.ID], win)...)
+			}
+			m.windows[win.ID] = win
+		}
+		m.windowsSynced = true
+	case *WindowOpenedOrChanged:
+		synthesized = windowEvents(m.windows[ev.Window.ID], ev.Window)
+		m.windows[ev.Window.ID] = ev.Window
+	case *WindowClosed:
+		delete(m.windows, ev.ID)
+	case *WorkspacesChanged:
+		if m.workspacesSynced {
+			synthesized = workspaceEvents(m.workspaces, ev.Workspaces)
+		}
+		m.workspacesSynced = true
+		// Remove workspaces that are no longer present
+		newWorkspaceIDs := make(map[uint64]struct{})
+		for _, workspace := range ev.Workspaces {
+			newWorkspaceIDs[workspace.ID] = struct{}{}
+		}
+		for id := range m.workspaces {
+			if _, found := newWorkspaceIDs[id]; !found {
+				slog.Debug("Removing workspace from existing workspaces", "id", id)
+				delete(m.workspaces, id)
+			}
+		}
+		for _, workspace := range ev.Workspaces {
+			m.workspaces[workspace.ID] = workspace
+		}
+	}
+	return synthesized
+}
@@ -197,6 +197,17 @@ type WindowLayoutsChanged struct {
 	Changes []WindowLayoutChange `json:"changes"`
 }
 
+// GetPossibleKeys returns the window of the change, if only one window changed.
+func (w WindowLayoutsChanged) GetPossibleKeys() models.PossibleKeys {
+	if len(w.Changes) != 1 {
+		return models.PossibleKeys{}
+	}
+	return models.PossibleKeys{
+		ID:       w.Changes[0].WindowID,
+		WindowID: w.Changes[0].WindowID,
+	}
+}
+
 // KeyboardLayoutsChanged when the configured keyboard layouts have changed.
 type KeyboardLayoutsChanged struct {
 	EName
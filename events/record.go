@@ -0,0 +1,95 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// RecordedEvent is a line of the niri event stream, recorded with the time it was received.
+//
+// The recordings are JSON lines, e.g.
+//
+//	{"time":"2025-09-01T12:00:00.123456789+03:00","event":{"WindowFocusChanged":{"id":12}}}
+type RecordedEvent struct {
+	// Time is the time the event was received.
+	Time time.Time `json:"time"`
+	// Event is the raw event, as sent by niri.
+	Event json.RawMessage `json:"event"`
+}
+
+// Record writes the lines of the niri event stream with their timestamps to the writer, until the stream ends.
+func Record(w io.Writer) error {
+	lines, err := RawEventStream()
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	for line := range lines {
+		if !json.Valid(line) {
+			slog.Error("Could not record invalid event", "line", string(line))
+			continue
+		}
+		if err := encoder.Encode(RecordedEvent{Time: time.Now(), Event: line}); err != nil {
+			return fmt.Errorf("could not write event: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReadRecording reads the recorded events from the reader.
+func ReadRecording(r io.Reader) ([]RecordedEvent, error) {
+	var recorded []RecordedEvent
+	scanner := bufio.NewScanner(r)
+	// The WindowsChanged events contain all the windows, so they can be long.
+	scanner.Buffer(nil, 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event RecordedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("invalid recorded event on line %d: %w", lineNumber, err)
+		}
+		recorded = append(recorded, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read recording: %w", err)
+	}
+	return recorded, nil
+}
+
+// Replay returns the recorded events parsed into event models, like the EventStream.
+//
+// The events are sent with the recorded intervals between them, divided by the speed, e.g. with a speed of 2
+// the events are replayed twice as fast as they were recorded. With a speed of 0 there are no intervals.
+func Replay(recorded []RecordedEvent, speed float64) <-chan Event {
+	stream := make(chan Event)
+
+	go func() {
+		defer close(stream)
+
+		for i, recordedEvent := range recorded {
+			if i > 0 && speed > 0 {
+				interval := recordedEvent.Time.Sub(recorded[i-1].Time)
+				time.Sleep(time.Duration(float64(interval) / speed))
+			}
+			var event map[string]json.RawMessage
+			if err := json.Unmarshal(recordedEvent.Event, &event); err != nil {
+				slog.Error("Error decoding JSON", "error", err.Error())
+				continue
+			}
+			_, model, err := ParseEvent(event)
+			if err != nil {
+				slog.Error("Could not parse event!", "event", event, "error", err.Error())
+				continue
+			}
+			stream <- model
+		}
+	}()
+
+	return stream
+}
@@ -0,0 +1,201 @@
+package events
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/soderluk/nirimgr/internal/common"
+	"github.com/soderluk/nirimgr/internal/connection"
+	"github.com/soderluk/nirimgr/models"
+)
+
+// RunSteps performs the steps in order on the model.
+//
+// The model is refreshed from niri before each step, so the conditions see the current state of the window
+// or workspace. An event is replaced with the window, or the workspace, it refers to. The steps stop if
+// the window or workspace is gone. RunSteps blocks until all the steps are done,
+// so the events daemon runs it in its own goroutine. The source is the rule or event handler of the steps,
+// shown in the dry-run output.
+func RunSteps(source string, steps []models.Step, model any, possibleKeys models.PossibleKeys) {
+	for _, step := range steps {
+		delay, err := step.DelayDuration()
+		if err != nil {
+			slog.Error("Could not run step", "error", err.Error())
+			return
+		}
+		time.Sleep(delay)
+
+		var ok bool
+		model, ok = refreshModel(model)
+		if !ok {
+			slog.Debug("Model is gone, not running the remaining steps")
+			return
+		}
+		evaluationResult, err := EvaluateCondition(step.When, model)
+		if err != nil {
+			slog.Error("Error in EvaluateCondition", slog.Any("error", err))
+		}
+		if !evaluationResult {
+			RunSteps(source, step.Else, model, possibleKeys)
+			continue
+		}
+		model = performStepActions(source, step, model, possibleKeys)
+		RunSteps(source, step.Then, model, possibleKeys)
+	}
+}
+
+// performStepActions performs the actions of the step, retrying them until the retry condition holds.
+//
+// Returns the refreshed model if the step was retried.
+func performStepActions(source string, step models.Step, model any, possibleKeys models.PossibleKeys) any {
+	attempts, interval := 1, time.Duration(0)
+	if step.Retry != nil {
+		attempts, interval = step.Retry.Limits()
+	}
+	for attempt := 1; ; attempt++ {
+		for actionName, actionConfig := range step.Actions {
+			// The steps run in the background, so they can sleep without blocking the other events.
+			if actionName == SleepAction {
+				performConditionalAction(source, actionName, actionConfig, model, possibleKeys)
+				continue
+			}
+			performConfiguredAction(source, actionName, actionConfig, model, possibleKeys)
+		}
+		if step.Retry == nil {
+			return model
+		}
+
+		// Give niri time to perform the actions before checking the condition.
+		time.Sleep(interval)
+		refreshed, ok := refreshModel(model)
+		if !ok {
+			return model
+		}
+		model = refreshed
+		done, err := EvaluateCondition(step.Retry.Until, model)
+		if err != nil {
+			slog.Error("Error in EvaluateCondition", slog.Any("error", err))
+			return model
+		}
+		if done {
+			return model
+		}
+		if attempt >= attempts {
+			slog.Warn("Step didn't succeed, giving up", "until", step.Retry.Until, "attempts", attempts)
+			return model
+		}
+		slog.Debug("Retrying step", "until", step.Retry.Until, "attempt", attempt)
+	}
+}
+
+// listWindows is a variable that points to connection.ListWindows, allowing us to mock it in tests.
+var listWindows = connection.ListWindows
+
+// listWorkspaces is a variable that points to connection.ListWorkspaces, allowing us to mock it in tests.
+var listWorkspaces = connection.ListWorkspaces
+
+// refreshModel returns the current state of the window or workspace from niri.
+//
+// The events are resolved to the window, or the workspace, they refer to, so the steps of the configured events
+// see its current state too. The events referring to neither, or to a window or workspace that's already gone,
+// e.g. WindowClosed, are returned as they are.
+// Returns false if the window or workspace doesn't exist anymore.
+func refreshModel(model any) (any, bool) {
+	switch m := model.(type) {
+	case *models.Window:
+		windows, err := listWindows()
+		if err != nil {
+			slog.Error("Could not get windows", "error", err.Error())
+			return model, true
+		}
+		window, err := common.FilterWindowsChain(windows, func(w *models.Window) bool {
+			return w.ID == m.ID
+		}).First()
+		if err != nil {
+			return nil, false
+		}
+		window.Matched, window.Sticky = m.Matched, m.Sticky
+		return window, true
+	case *models.Workspace:
+		workspaces, err := listWorkspaces()
+		if err != nil {
+			slog.Error("Could not get workspaces", "error", err.Error())
+			return model, true
+		}
+		workspace, err := common.FilterWorkspacesChain(workspaces, func(w *models.Workspace) bool {
+			return w.ID == m.ID
+		}).First()
+		if err != nil {
+			return nil, false
+		}
+		workspace.Matched = m.Matched
+		return workspace, true
+	case Event:
+		return refreshEvent(m)
+	}
+	return model, true
+}
+
+// refreshEvent returns the current state of the window, or the workspace, the event refers to.
+func refreshEvent(ev Event) (any, bool) {
+	existingWindows, existingWorkspaces, err := currentModels()
+	if err != nil {
+		slog.Error("Could not get the windows and workspaces", "error", err.Error())
+		return ev, true
+	}
+	// The window and workspace the event carries are from the time of the event, so they're looked up again.
+	window, workspace := eventModels(ev, existingWindows, existingWorkspaces)
+	if window != nil {
+		if current, ok := existingWindows[window.ID]; ok {
+			return current, true
+		}
+	}
+	if workspace != nil {
+		if current, ok := existingWorkspaces[workspace.ID]; ok {
+			return current, true
+		}
+	}
+	return ev, true
+}
+
+// currentModels returns the current windows and workspaces from niri, keyed by their IDs.
+func currentModels() (map[uint64]*models.Window, map[uint64]*models.Workspace, error) {
+	windows, err := listWindows()
+	if err != nil {
+		return nil, nil, err
+	}
+	workspaces, err := listWorkspaces()
+	if err != nil {
+		return nil, nil, err
+	}
+	existingWindows := make(map[uint64]*models.Window, len(windows))
+	for _, window := range windows {
+		existingWindows[window.ID] = window
+	}
+	existingWorkspaces := make(map[uint64]*models.Workspace, len(workspaces))
+	for _, workspace := range workspaces {
+		existingWorkspaces[workspace.ID] = workspace
+	}
+	return existingWindows, existingWorkspaces, nil
+}
+
+// copyModel returns a copy of the matched window or workspace, so the steps running in the background
+// don't share it with the event loop.
+func copyModel(model any) any {
+	switch m := model.(type) {
+	case *models.Window:
+		window := *m
+		return &window
+	case *models.Workspace:
+		workspace := *m
+		return &workspace
+	case *WindowOpenedOrChanged:
+		event := *m
+		if m.Window != nil {
+			window := *m.Window
+			event.Window = &window
+		}
+		return &event
+	}
+	return model
+}
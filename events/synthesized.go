@@ -0,0 +1,288 @@
+package events
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/soderluk/nirimgr/models"
+)
+
+// The synthesized events are not sent by niri, but derived by nirimgr from the changes between the previous
+// and the new window and workspace state in the events daemon. They can be configured in Config.Events like
+// the niri events, and are handled right after the niri event they were derived from.
+
+// WindowOpened when a new window was opened.
+type WindowOpened struct {
+	EName
+	// Window contains the opened window.
+	Window *models.Window `json:"window"`
+}
+
+// GetPossibleKeys extracts the window and workspace IDs from this event.
+func (w WindowOpened) GetPossibleKeys() models.PossibleKeys {
+	if w.Window == nil {
+		return models.PossibleKeys{}
+	}
+	return models.PossibleKeys{
+		ID:          w.Window.ID,
+		WindowID:    w.Window.ID,
+		WorkspaceID: w.Window.WorkspaceID,
+	}
+}
+
+// WindowTitleChanged when the title of a window changed.
+type WindowTitleChanged struct {
+	EName
+	// ID the ID of the window.
+	ID uint64 `json:"id"`
+	// Title the new title of the window.
+	Title string `json:"title"`
+	// OldTitle the previous title of the window.
+	OldTitle string `json:"old_title"`
+}
+
+// GetPossibleKeys extracts the window ID from this event.
+func (w WindowTitleChanged) GetPossibleKeys() models.PossibleKeys {
+	return models.PossibleKeys{
+		ID:       w.ID,
+		WindowID: w.ID,
+	}
+}
+
+// WindowAppIDChanged when the app ID of a window changed.
+type WindowAppIDChanged struct {
+	EName
+	// ID the ID of the window.
+	ID uint64 `json:"id"`
+	// AppID the new app ID of the window.
+	AppID string `json:"app_id"`
+	// OldAppID the previous app ID of the window.
+	OldAppID string `json:"old_app_id"`
+}
+
+// GetPossibleKeys extracts the window ID from this event.
+func (w WindowAppIDChanged) GetPossibleKeys() models.PossibleKeys {
+	return models.PossibleKeys{
+		ID:       w.ID,
+		WindowID: w.ID,
+	}
+}
+
+// WindowMovedToWorkspace when a window was moved to another workspace.
+type WindowMovedToWorkspace struct {
+	EName
+	// ID the ID of the window.
+	ID uint64 `json:"id"`
+	// WorkspaceID the ID of the workspace the window was moved to.
+	WorkspaceID uint64 `json:"workspace_id"`
+	// OldWorkspaceID the ID of the workspace the window was moved from.
+	OldWorkspaceID uint64 `json:"old_workspace_id"`
+}
+
+// GetPossibleKeys extracts the window and the new workspace IDs from this event.
+func (w WindowMovedToWorkspace) GetPossibleKeys() models.PossibleKeys {
+	return models.PossibleKeys{
+		ID:          w.ID,
+		WindowID:    w.ID,
+		WorkspaceID: w.WorkspaceID,
+	}
+}
+
+// WindowFloatingChanged when a window was moved between the floating and the tiling layout.
+type WindowFloatingChanged struct {
+	EName
+	// ID the ID of the window.
+	ID uint64 `json:"id"`
+	// IsFloating tells if the window is now floating.
+	IsFloating bool `json:"is_floating"`
+}
+
+// GetPossibleKeys extracts the window ID from this event.
+func (w WindowFloatingChanged) GetPossibleKeys() models.PossibleKeys {
+	return models.PossibleKeys{
+		ID:       w.ID,
+		WindowID: w.ID,
+	}
+}
+
+// WorkspaceCreated when a new workspace was created.
+type WorkspaceCreated struct {
+	EName
+	// Workspace contains the created workspace.
+	Workspace *models.Workspace `json:"workspace"`
+}
+
+// GetPossibleKeys extracts the workspace ID from this event.
+func (w WorkspaceCreated) GetPossibleKeys() models.PossibleKeys {
+	if w.Workspace == nil {
+		return models.PossibleKeys{}
+	}
+	return models.PossibleKeys{
+		ID:          w.Workspace.ID,
+		WorkspaceID: w.Workspace.ID,
+	}
+}
+
+// WorkspaceRemoved when a workspace was removed.
+type WorkspaceRemoved struct {
+	EName
+	// Workspace contains the removed workspace, as it was before it was removed.
+	Workspace *models.Workspace `json:"workspace"`
+}
+
+// GetPossibleKeys extracts the workspace ID from this event.
+func (w WorkspaceRemoved) GetPossibleKeys() models.PossibleKeys {
+	if w.Workspace == nil {
+		return models.PossibleKeys{}
+	}
+	return models.PossibleKeys{
+		ID:          w.Workspace.ID,
+		WorkspaceID: w.Workspace.ID,
+	}
+}
+
+// WorkspaceMovedToOutput when a workspace was moved to another output.
+type WorkspaceMovedToOutput struct {
+	EName
+	// ID the ID of the workspace.
+	ID uint64 `json:"id"`
+	// Output the name of the output the workspace was moved to.
+	Output string `json:"output"`
+	// OldOutput the name of the output the workspace was moved from.
+	OldOutput string `json:"old_output"`
+}
+
+// GetPossibleKeys extracts the workspace ID from this event.
+func (w WorkspaceMovedToOutput) GetPossibleKeys() models.PossibleKeys {
+	return models.PossibleKeys{
+		ID:          w.ID,
+		WorkspaceID: w.ID,
+	}
+}
+
+// OutputConnected when an output was connected.
+//
+// niri doesn't send output events, so the output is considered connected when it gets its first workspace.
+type OutputConnected struct {
+	EName
+	// Name the name of the output, e.g. "DP-1".
+	Name string `json:"name"`
+}
+
+// OutputDisconnected when an output was disconnected.
+//
+// niri doesn't send output events, so the output is considered disconnected when it has no workspaces left.
+type OutputDisconnected struct {
+	EName
+	// Name the name of the output, e.g. "DP-1".
+	Name string `json:"name"`
+}
+
+// SynthesizedEventRegistry contains the events nirimgr derives from the state changes.
+//
+// Like the EventRegistry, the key needs to be the event name, and it should return the event model with its EName set.
+var SynthesizedEventRegistry = map[string]func() Event{
+	"OutputConnected":        func() Event { return &OutputConnected{EName: EName{Name: "OutputConnected"}} },
+	"OutputDisconnected":     func() Event { return &OutputDisconnected{EName: EName{Name: "OutputDisconnected"}} },
+	"WindowAppIDChanged":     func() Event { return &WindowAppIDChanged{EName: EName{Name: "WindowAppIDChanged"}} },
+	"WindowFloatingChanged":  func() Event { return &WindowFloatingChanged{EName: EName{Name: "WindowFloatingChanged"}} },
+	"WindowMovedToWorkspace": func() Event { return &WindowMovedToWorkspace{EName: EName{Name: "WindowMovedToWorkspace"}} },
+	"WindowOpened":           func() Event { return &WindowOpened{EName: EName{Name: "WindowOpened"}} },
+	"WindowTitleChanged":     func() Event { return &WindowTitleChanged{EName: EName{Name: "WindowTitleChanged"}} },
+	"WorkspaceCreated":       func() Event { return &WorkspaceCreated{EName: EName{Name: "WorkspaceCreated"}} },
+	"WorkspaceMovedToOutput": func() Event { return &WorkspaceMovedToOutput{EName: EName{Name: "WorkspaceMovedToOutput"}} },
+	"WorkspaceRemoved":       func() Event { return &WorkspaceRemoved{EName: EName{Name: "WorkspaceRemoved"}} },
+}
+
+// synthesize returns the synthesized event with the given name, set up by the given function.
+func synthesize[T Event](name string, set func(T)) Event {
+	event := SynthesizedEventRegistry[name]().(T)
+	set(event)
+	return event
+}
+
+// windowEvents returns the events synthesized from the change of the window.
+//
+// The previous window is nil, if the window wasn't known before.
+func windowEvents(previous, window *models.Window) []Event {
+	if previous == nil {
+		return []Event{synthesize("WindowOpened", func(e *WindowOpened) { e.Window = window })}
+	}
+	var events []Event
+	if previous.Title != window.Title {
+		events = append(events, synthesize("WindowTitleChanged", func(e *WindowTitleChanged) {
+			e.ID, e.Title, e.OldTitle = window.ID, window.Title, previous.Title
+		}))
+	}
+	if previous.AppID != window.AppID {
+		events = append(events, synthesize("WindowAppIDChanged", func(e *WindowAppIDChanged) {
+			e.ID, e.AppID, e.OldAppID = window.ID, window.AppID, previous.AppID
+		}))
+	}
+	if previous.WorkspaceID != window.WorkspaceID {
+		events = append(events, synthesize("WindowMovedToWorkspace", func(e *WindowMovedToWorkspace) {
+			e.ID, e.WorkspaceID, e.OldWorkspaceID = window.ID, window.WorkspaceID, previous.WorkspaceID
+		}))
+	}
+	if previous.IsFloating != window.IsFloating {
+		events = append(events, synthesize("WindowFloatingChanged", func(e *WindowFloatingChanged) {
+			e.ID, e.IsFloating = window.ID, window.IsFloating
+		}))
+	}
+	return events
+}
+
+// workspaceEvents returns the events synthesized from the change of all the workspaces.
+//
+// The outputs are connected and disconnected after the workspaces are created and removed, ordered by the output name.
+func workspaceEvents(previous map[uint64]*models.Workspace, workspaces []*models.Workspace) []Event {
+	var events []Event
+	previousOutputs := make(map[string]struct{})
+	for _, workspace := range previous {
+		previousOutputs[workspace.Output] = struct{}{}
+	}
+	outputs := make(map[string]struct{})
+	current := make(map[uint64]struct{})
+	for _, workspace := range workspaces {
+		outputs[workspace.Output] = struct{}{}
+		current[workspace.ID] = struct{}{}
+		old, ok := previous[workspace.ID]
+		switch {
+		case !ok:
+			events = append(events, synthesize("WorkspaceCreated", func(e *WorkspaceCreated) { e.Workspace = workspace }))
+		case old.Output != workspace.Output:
+			events = append(events, synthesize("WorkspaceMovedToOutput", func(e *WorkspaceMovedToOutput) {
+				e.ID, e.Output, e.OldOutput = workspace.ID, workspace.Output, old.Output
+			}))
+		}
+	}
+	var removed []*models.Workspace
+	for id, workspace := range previous {
+		if _, ok := current[id]; !ok {
+			removed = append(removed, workspace)
+		}
+	}
+	slices.SortFunc(removed, func(a, b *models.Workspace) int { return cmp.Compare(a.ID, b.ID) })
+	for _, workspace := range removed {
+		events = append(events, synthesize("WorkspaceRemoved", func(e *WorkspaceRemoved) { e.Workspace = workspace }))
+	}
+	for _, name := range sortedDifference(outputs, previousOutputs) {
+		events = append(events, synthesize("OutputConnected", func(e *OutputConnected) { e.Name = name }))
+	}
+	for _, name := range sortedDifference(previousOutputs, outputs) {
+		events = append(events, synthesize("OutputDisconnected", func(e *OutputDisconnected) { e.Name = name }))
+	}
+	return events
+}
+
+// sortedDifference returns the sorted keys of a that are not in b.
+func sortedDifference(a, b map[string]struct{}) []string {
+	var keys []string
+	for key := range a {
+		if _, ok := b[key]; !ok && key != "" {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,210 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/soderluk/nirimgr/models"
+)
+
+// TailFilter selects the events shown by `nirimgr events tail`.
+//
+// The conditions are expr-lang conditions like the "when" conditions of the actions. The window and workspace can also
+// be selected with "focused" or their ID, like in `nirimgr explain`, see ResolveFocused. Empty fields don't filter anything.
+type TailFilter struct {
+	// Names are the names of the events to show.
+	Names []string
+	// Window is the selector for the window the event refers to, e.g. "focused", "12" or "model.AppID == 'firefox'".
+	Window string
+	// Workspace is the selector for the workspace the event refers to, e.g. "focused", "2" or "model.Name == 'work'".
+	Workspace string
+	// When is the condition for the event itself, e.g. "model.Urgent".
+	When string
+}
+
+// Validate compiles the conditions of the filter, so e.g. a typo in them is an error before any events arrive.
+//
+// The window and workspace conditions are checked against the fields of the window and workspace. The events have
+// different fields, so only the syntax of the event condition is checked. The focused and ID selectors are always valid.
+func (f TailFilter) Validate() error {
+	// anyModel is the environment of the event condition, where the model can be any event.
+	type anyModel struct {
+		Model any `expr:"model"`
+	}
+	conditions := []struct {
+		name      string
+		condition string
+		env       any
+	}{
+		{"window", f.Window, map[string]any{"model": &models.Window{}}},
+		{"workspace", f.Workspace, map[string]any{"model": &models.Workspace{}}},
+		{"event", f.When, anyModel{}},
+	}
+	for _, c := range conditions {
+		if c.condition == "" || (c.name != "event" && !isConditionSelector(c.condition)) {
+			continue
+		}
+		if _, err := expr.Compile(c.condition, expr.Env(c.env), expr.AsBool()); err != nil {
+			return fmt.Errorf("invalid %s condition '%s': %w", c.name, c.condition, err)
+		}
+	}
+	return nil
+}
+
+// ResolveFocused returns the filter with the "focused" window and workspace selectors replaced with their IDs.
+//
+// The focus isn't tracked from the events, so "focused" selects the window or workspace focused when the tail starts.
+func (f TailFilter) ResolveFocused() (TailFilter, error) {
+	if f.Window == models.TargetFocused {
+		window, err := FindWindow(f.Window)
+		if err != nil {
+			return f, err
+		}
+		f.Window = strconv.FormatUint(window.ID, 10)
+	}
+	if f.Workspace == models.TargetFocused {
+		workspace, err := FindWorkspace(f.Workspace)
+		if err != nil {
+			return f, err
+		}
+		f.Workspace = strconv.FormatUint(workspace.ID, 10)
+	}
+	return f, nil
+}
+
+// Matches checks if the tailed event passes the filter.
+//
+// The events without a window or workspace don't pass the window or workspace selectors.
+func (f TailFilter) Matches(tailed TailedEvent) (bool, error) {
+	if len(f.Names) > 0 && !slices.Contains(f.Names, tailed.Name) {
+		return false, nil
+	}
+	if f.Window != "" {
+		if tailed.Window == nil {
+			return false, nil
+		}
+		matched, err := selectorMatches(f.Window, tailed.Window, tailed.Window.ID, tailed.Window.IsFocused)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	if f.Workspace != "" {
+		if tailed.Workspace == nil {
+			return false, nil
+		}
+		matched, err := selectorMatches(f.Workspace, tailed.Workspace, tailed.Workspace.ID, tailed.Workspace.IsFocused)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	if f.When != "" {
+		return EvaluateCondition(f.When, tailed.Event)
+	}
+	return true, nil
+}
+
+// TailedEvent is an event shown by `nirimgr events tail`, with the window and workspace it refers to.
+type TailedEvent struct {
+	// Time is the time the event was received.
+	Time time.Time `json:"time"`
+	// Name is the name of the event.
+	Name string `json:"name"`
+	// Synthesized tells if the event was synthesized by nirimgr, instead of sent by niri.
+	Synthesized bool `json:"synthesized,omitempty"`
+	// Event is the event.
+	Event Event `json:"-"`
+	// Fields are the fields of the event.
+	Fields map[string]any `json:"event"`
+	// Window is the window the event refers to, if any.
+	Window *models.Window `json:"window,omitempty"`
+	// Workspace is the workspace the event refers to, if any.
+	Workspace *models.Workspace `json:"workspace,omitempty"`
+}
+
+// maxFieldsLength is the length the event fields are cut to in the human-readable output.
+const maxFieldsLength = 200
+
+// String returns the event in a human-readable form, e.g.
+//
+//	12:00:01.123 WindowFocusChanged {"id":12} window 12 firefox "GitHub" on workspace 2 "work" (DP-1)
+func (t TailedEvent) String() string {
+	var b strings.Builder
+	b.WriteString(t.Time.Format("15:04:05.000") + " " + t.Name)
+	if t.Synthesized {
+		b.WriteString(" (synthesized)")
+	}
+	if fields, err := json.Marshal(t.Fields); err == nil && len(t.Fields) > 0 {
+		s := []rune(string(fields))
+		if len(s) > maxFieldsLength {
+			s = append(s[:maxFieldsLength], '…')
+		}
+		b.WriteString(" " + string(s))
+	}
+	if t.Window != nil {
+		fmt.Fprintf(&b, " window %d %v %q", t.Window.ID, t.Window.AppID, t.Window.Title)
+	}
+	if t.Workspace != nil {
+		if t.Window != nil {
+			b.WriteString(" on")
+		}
+		fmt.Fprintf(&b, " workspace %d %q (%v)", t.Workspace.ID, t.Workspace.Name, t.Workspace.Output)
+	}
+	return b.String()
+}
+
+// Tail calls show for the events passing the filter, until the events channel is closed.
+//
+// The windows and workspaces are tracked from the events to resolve the ones the events refer to,
+// and the events synthesized from their changes are shown too. Nothing is performed on the events.
+func Tail(events <-chan Event, filter TailFilter, show func(TailedEvent)) {
+	state := newMirror()
+	tail := func(ev Event, synthesized bool) {
+		window, workspace := eventModels(ev, state.windows, state.workspaces)
+		tailed := TailedEvent{
+			Time:        time.Now(),
+			Name:        ev.GetName(),
+			Synthesized: synthesized,
+			Event:       ev,
+			Fields:      eventFields(ev),
+			Window:      window,
+			Workspace:   workspace,
+		}
+		matched, err := filter.Matches(tailed)
+		if err != nil {
+			slog.Error("Could not filter the event", "name", tailed.Name, "error", err.Error())
+		}
+		if matched {
+			show(tailed)
+		}
+	}
+	for event := range events {
+		if event == nil {
+			continue
+		}
+		// The niri event is tailed before the update, so e.g. the closed window is still known.
+		tail(event, false)
+		for _, ev := range state.update(event) {
+			tail(ev, true)
+		}
+	}
+}
+
+// eventFields returns the fields of the event, without its name.
+func eventFields(ev Event) map[string]any {
+	var fields map[string]any
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	delete(fields, "Name")
+	return fields
+}
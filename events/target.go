@@ -0,0 +1,190 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/soderluk/nirimgr/actions"
+	"github.com/soderluk/nirimgr/internal/common"
+	"github.com/soderluk/nirimgr/internal/connection"
+	"github.com/soderluk/nirimgr/models"
+)
+
+// targetAction sets the IDs of the target on the action.
+func targetAction(a actions.Action, target string, params json.RawMessage, model any, possibleKeys models.PossibleKeys) (actions.Action, error) {
+	switch target {
+	case "":
+		return actions.HandleDynamicIDs(a, possibleKeys), nil
+	case models.TargetNone:
+		return a, nil
+	}
+	windowID, workspaceID, err := resolveTarget(target, model)
+	if err != nil {
+		return nil, err
+	}
+	return actions.ApplyTarget(a, params, windowID, workspaceID), nil
+}
+
+// resolveTarget returns the IDs of the window and workspace the target refers to.
+//
+// The model is the matched window or workspace, or the event. Either of the IDs can be 0 if the target
+// doesn't have one, e.g. a workspace has no window, but an error is returned if neither is found.
+func resolveTarget(target string, model any) (uint64, uint64, error) {
+	var windowID, workspaceID uint64
+	var err error
+	switch {
+	case target == models.TargetSelf:
+		windowID, workspaceID = modelIDs(model)
+	case target == models.TargetWorkspace:
+		_, workspaceID = modelIDs(model)
+	case target == models.TargetActiveWindow:
+		windowID, workspaceID = modelIDs(model)
+		if workspace, ok := model.(*models.Workspace); ok {
+			windowID = workspace.ActiveWindowID
+		} else if keys, ok := model.(Event); ok && keys.GetPossibleKeys().ActiveWindowID != 0 {
+			windowID = keys.GetPossibleKeys().ActiveWindowID
+		} else if workspaceID != 0 {
+			windowID, err = activeWindowID(workspaceID)
+		}
+	case target == models.TargetFocused:
+		windowID, workspaceID, err = focusedIDs()
+	case strings.HasPrefix(target, "window:"):
+		windowID, workspaceID, err = selectWindow(strings.TrimPrefix(target, "window:"))
+	case strings.HasPrefix(target, "workspace:"):
+		workspaceID, err = selectWorkspace(strings.TrimPrefix(target, "workspace:"))
+	default:
+		return 0, 0, fmt.Errorf("unknown target '%v'", target)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if windowID == 0 && workspaceID == 0 {
+		return 0, 0, fmt.Errorf("target '%v' didn't match a window or workspace", target)
+	}
+	return windowID, workspaceID, nil
+}
+
+// modelIDs returns the IDs of the window and workspace of the matched window, workspace or event.
+func modelIDs(model any) (uint64, uint64) {
+	switch m := model.(type) {
+	case *models.Window:
+		return m.ID, m.WorkspaceID
+	case *models.Workspace:
+		return 0, m.ID
+	case *WindowOpenedOrChanged:
+		if m.Window != nil {
+			return m.Window.ID, m.Window.WorkspaceID
+		}
+	case Event:
+		keys := m.GetPossibleKeys()
+		return keys.WindowID, keys.WorkspaceID
+	}
+	return 0, 0
+}
+
+// eventModels returns the window and workspace the event refers to, from the event itself or the daemon's state.
+//
+// The window's workspace is used for the window events. Either one is nil if the event doesn't refer to one,
+// or it's not known.
+func eventModels(ev Event, existingWindows map[uint64]*models.Window, existingWorkspaces map[uint64]*models.Workspace) (*models.Window, *models.Workspace) {
+	var window *models.Window
+	var workspace *models.Workspace
+	switch e := ev.(type) {
+	case *WindowOpenedOrChanged:
+		window = e.Window
+	case *WindowOpened:
+		window = e.Window
+	case *WorkspaceCreated:
+		workspace = e.Workspace
+	case *WorkspaceRemoved:
+		workspace = e.Workspace
+	}
+	keys := ev.GetPossibleKeys()
+	if window == nil {
+		windowID := keys.WindowID
+		if windowID == 0 {
+			windowID = keys.ActiveWindowID
+		}
+		window = existingWindows[windowID]
+	}
+	if workspace == nil {
+		workspaceID := keys.WorkspaceID
+		if workspaceID == 0 && window != nil {
+			workspaceID = window.WorkspaceID
+		}
+		workspace = existingWorkspaces[workspaceID]
+	}
+	return window, workspace
+}
+
+// activeWindowID returns the ID of the active window on the workspace.
+func activeWindowID(workspaceID uint64) (uint64, error) {
+	workspaces, err := connection.ListWorkspaces()
+	if err != nil {
+		return 0, errors.New("could not get workspaces")
+	}
+	workspace, err := common.FilterWorkspacesChain(workspaces, func(w *models.Workspace) bool {
+		return w.ID == workspaceID
+	}).First()
+	if err != nil {
+		return 0, err
+	}
+	return workspace.ActiveWindowID, nil
+}
+
+// focusedIDs returns the IDs of the focused window and the focused workspace.
+func focusedIDs() (uint64, uint64, error) {
+	windows, err := connection.ListWindows()
+	if err != nil {
+		return 0, 0, errors.New("could not get windows")
+	}
+	if window, err := common.FilterWindowsChain(windows, func(w *models.Window) bool {
+		return w.IsFocused
+	}).First(); err == nil {
+		return window.ID, window.WorkspaceID, nil
+	}
+	workspaceID, err := selectWorkspace("model.IsFocused")
+	return 0, workspaceID, err
+}
+
+// selectWindow returns the IDs of the first window, and its workspace, for which the condition evaluates to true.
+func selectWindow(condition string) (uint64, uint64, error) {
+	windows, err := connection.ListWindows()
+	if err != nil {
+		return 0, 0, errors.New("could not get windows")
+	}
+	for _, window := range (models.WindowSlice{Windows: windows}).SortByID().Windows {
+		matches, err := EvaluateCondition(strings.TrimSpace(condition), window)
+		if err != nil {
+			return 0, 0, err
+		}
+		if matches {
+			return window.ID, window.WorkspaceID, nil
+		}
+	}
+	return 0, 0, nil
+}
+
+// selectWorkspace returns the ID of the first workspace for which the condition evaluates to true.
+func selectWorkspace(condition string) (uint64, error) {
+	workspaces, err := connection.ListWorkspaces()
+	if err != nil {
+		return 0, errors.New("could not get workspaces")
+	}
+	var selected uint64
+	for _, workspace := range workspaces {
+		if selected != 0 && workspace.ID > selected {
+			continue
+		}
+		matches, err := EvaluateCondition(strings.TrimSpace(condition), workspace)
+		if err != nil {
+			return 0, err
+		}
+		if matches {
+			selected = workspace.ID
+		}
+	}
+	return selected, nil
+}
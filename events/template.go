@@ -0,0 +1,232 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+	"github.com/soderluk/nirimgr/models"
+)
+
+// RenderParams replaces the ${expr} templates in the string values of the action params.
+//
+// The expressions are evaluated with expr-lang, like the "when" conditions, against the model, i.e. the matched
+// window, workspace or event, and the window and workspace it refers to as `window` and `workspace`. E.g. "${model.AppID}"
+// is replaced with the app-id of the matched window, and "${window.Title}" with the title of the focused window of
+// a WindowFocusChanged event. A string that is a single template gets the type of the result, so "${model.ID}" becomes
+// a number. Only the expressions using these variables are templates, so e.g. "${HOME}" in a shell command is kept as it is.
+// A literal "${" is written as "$${".
+func RenderParams(params json.RawMessage, model any) (json.RawMessage, error) {
+	if !bytes.Contains(params, []byte("${")) {
+		return params, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	// Keep the numbers as they are, instead of converting e.g. the IDs to floats.
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	rendered, err := renderValue(value, model)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rendered)
+}
+
+// renderValue replaces the templates in all the strings of the decoded JSON value.
+func renderValue(value any, model any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return renderString(v, model)
+	case []any:
+		for i, item := range v {
+			rendered, err := renderValue(item, model)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = rendered
+		}
+	case map[string]any:
+		for key, item := range v {
+			rendered, err := renderValue(item, model)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = rendered
+		}
+	}
+	return value, nil
+}
+
+// renderString replaces the templates in the string.
+func renderString(s string, model any) (any, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			b.WriteString("${")
+			i += 3
+		case strings.HasPrefix(s[i:], "${"):
+			end, ok, err := template(s, i+2)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				b.WriteString("${")
+				i += 2
+				continue
+			}
+			result, err := evaluateExpression(s[i+2:end], model)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 && end == len(s)-1 {
+				return result, nil
+			}
+			fmt.Fprint(&b, result)
+			i = end + 1
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// templateRoots are the variables of the template expressions. The expressions without them aren't templates.
+var templateRoots = []string{"model", "window", "workspace"}
+
+// template checks if the "${" ending at start begins a template, and returns the index of its closing brace.
+//
+// The text that isn't an expression using the template roots is not a template, e.g. "${HOME}" or "${VAR:-x}"
+// of a shell command. The text that starts with a template root, but isn't a valid expression is an error.
+func template(s string, start int) (int, bool, error) {
+	rooted := slices.Contains(templateRoots, leadingIdentifier(s[start:]))
+	end, err := templateEnd(s, start)
+	if err != nil {
+		if rooted {
+			return 0, false, err
+		}
+		return 0, false, nil
+	}
+	tree, err := parser.Parse(s[start:end])
+	if err != nil {
+		if rooted {
+			return 0, false, fmt.Errorf("invalid template '%s': %w", s[start:end], err)
+		}
+		return 0, false, nil
+	}
+	visitor := &rootVisitor{}
+	ast.Walk(&tree.Node, visitor)
+	return end, len(visitor.roots) > 0, nil
+}
+
+// leadingIdentifier returns the identifier the text starts with, ignoring the leading spaces.
+func leadingIdentifier(s string) string {
+	s = strings.TrimLeft(s, " ")
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if end == -1 {
+		return s
+	}
+	return s[:end]
+}
+
+// rootVisitor finds the uses of the template roots in an expression.
+type rootVisitor struct {
+	roots []string
+}
+
+// Visit checks if the node is one of the template roots.
+func (v *rootVisitor) Visit(node *ast.Node) {
+	if identifier, ok := (*node).(*ast.IdentifierNode); ok && slices.Contains(templateRoots, identifier.Value) {
+		v.roots = append(v.roots, identifier.Value)
+	}
+}
+
+// templateEnd returns the index of the closing brace of the template starting at start.
+//
+// The braces inside the expression, e.g. in a map literal or a string, don't end the template.
+func templateEnd(s string, start int) (int, error) {
+	depth := 0
+	var quote byte
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			if depth == 0 {
+				return i, nil
+			}
+			depth--
+		}
+	}
+	return 0, fmt.Errorf("unterminated template in '%s'", s)
+}
+
+// templateEnv is the environment of the template expressions.
+type templateEnv struct {
+	Model     any               `expr:"model"`
+	Window    *models.Window    `expr:"window"`
+	Workspace *models.Workspace `expr:"workspace"`
+}
+
+// evaluateExpression evaluates the template expression against the model, and the window and workspace it refers to.
+func evaluateExpression(expression string, model any) (any, error) {
+	program, err := expr.Compile(expression, expr.Env(templateEnv{}))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template '%s': %w", expression, err)
+	}
+	env := templateEnv{Model: model}
+	// Only look up the window and workspace if they're used, so the other templates don't need to ask niri.
+	visitor := &rootVisitor{}
+	node := program.Node()
+	ast.Walk(&node, visitor)
+	if slices.Contains(visitor.roots, "window") || slices.Contains(visitor.roots, "workspace") {
+		env.Window, env.Workspace = templateModels(model)
+	}
+	result, err := expr.Run(program, env)
+	if err != nil {
+		return nil, fmt.Errorf("error evaluating template '%s': %w", expression, err)
+	}
+	return result, nil
+}
+
+// templateModels returns the window and workspace the model refers to, as niri currently sees them.
+//
+// The window's workspace is used for a window, and the active window for a workspace. The events are resolved
+// like the steps resolve them. Either one is nil if the model doesn't refer to one, or it's gone.
+func templateModels(model any) (*models.Window, *models.Workspace) {
+	existingWindows, existingWorkspaces, err := currentModels()
+	if err != nil {
+		slog.Error("Could not get the windows and workspaces for the template", "error", err.Error())
+	}
+	switch m := model.(type) {
+	case *models.Window:
+		return m, existingWorkspaces[m.WorkspaceID]
+	case *models.Workspace:
+		return existingWindows[m.ActiveWindowID], m
+	case Event:
+		return eventModels(m, existingWindows, existingWorkspaces)
+	}
+	return nil, nil
+}
@@ -30,5 +30,5 @@ require (
 	github.com/olekukonko/ll v0.1.8 // indirect
 	github.com/spf13/pflag v1.0.10 // indirect
 	github.com/stretchr/testify v1.11.1
-	golang.org/x/sys v0.46.0 // indirect
+	golang.org/x/sys v0.46.0
 )
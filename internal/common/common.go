@@ -2,12 +2,13 @@
 package common
 
 import (
-	"bytes"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
 	"reflect"
+	"slices"
 
 	"github.com/soderluk/nirimgr/config"
 	"github.com/soderluk/nirimgr/models"
@@ -149,63 +150,25 @@ func FilterOutputsChain(outputs []*models.Output, f func(*models.Output) bool) m
 // execCommand is a variable that points to exec.Command, allowing us to mock it in tests.
 var execCommand = exec.Command
 
-// RunCommand runs the given command with sh and returns the result in bytes.
-func RunCommand(command string) ([]byte, error) {
-	// Validate the command before executing.
-	if err := validateCommand(command); err != nil {
-		return nil, err
-	}
-
-	cmd := execCommand("sh", "-c", command)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
-	if err != nil {
-		return nil, fmt.Errorf("%w: %s", err, stderr.String())
-	}
-
-	return stdout.Bytes(), nil
-}
-
-// validateCommand checks the command for common dangerous patterns.
-func validateCommand(command string) error {
-	patterns := []string{
-		"rm -rf",
-		"rm -fr",
-		"rm / -rf",
-		"rm / -fr",
-		"> /dev/sda",
-		"dd if=",
-		"mkfs",
-		"shred",
-		":(){ :|:& };:",
-		"fork()",
-		"chmod -R 777",
-		"chown -R",
-		"sudo rm",
-		"sudo dd",
-		"sudo mkfs",
-		"> /etc/",
-		"> /boot/",
-		"> /sys/",
-		"format c:",
-		"del /f /s /q",
+// StartDetached starts the command in its own session, without waiting for it to exit.
+//
+// The command is executed directly without a shell, in the given working directory and with the
+// additional environment variables. This is used instead of niri's Spawn action when we need to set
+// the directory or environment, which the Spawn action doesn't support.
+func StartDetached(command []string, dir string, env map[string]string) error {
+	if len(command) == 0 {
+		return fmt.Errorf("empty command")
 	}
-
-	for _, pattern := range patterns {
-		if bytes.Contains([]byte(command), []byte(pattern)) {
-			return fmt.Errorf("potentially dangerous command detected: contains '%s'", pattern)
-		}
+	cmd := execCommand(command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	keys := slices.Sorted(maps.Keys(env))
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+env[key])
 	}
-
-	// Block commands that start with sudo or su for privilege escalation
-	trimmed := bytes.TrimSpace([]byte(command))
-	if bytes.HasPrefix(trimmed, []byte("sudo ")) || bytes.HasPrefix(trimmed, []byte("su ")) {
-		return fmt.Errorf("privilege escalation commands (sudo/su) are not allowed")
+	setsid(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
 	}
-
-	return nil
+	return cmd.Process.Release()
 }
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package common
+
+import "os/exec"
+
+// setsid is a no-op on platforms without sessions.
+func setsid(cmd *exec.Cmd) {}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package common
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setsid makes the command start in a new session, so it keeps running after nirimgr exits.
+func setsid(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -12,6 +12,7 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"os"
@@ -38,6 +39,33 @@ var pool = sync.Pool{
 	},
 }
 
+// DryRun is where the actions are printed instead of performing them, if set.
+//
+// The requests to niri are still sent, so the state is read from niri as usual.
+var DryRun io.Writer
+
+// Origin tells which rule or event handler an action came from, shown in the dry-run output.
+type Origin struct {
+	// Source is the rule or event handler, e.g. "rule 2 (window)" or "event WindowFocusChanged".
+	Source string
+	// When is the condition of the action, if any.
+	When string
+	// Result is what the condition evaluated to.
+	Result bool
+}
+
+// String returns the origin as shown in the dry-run output, e.g. `rule 2 (window), when "model.IsFloating" is true`.
+func (o Origin) String() string {
+	if o.When == "" {
+		return o.Source
+	}
+	when := fmt.Sprintf("when %q is %v", o.When, o.Result)
+	if o.Source == "" {
+		return when
+	}
+	return o.Source + ", " + when
+}
+
 // Socket can be used to get the NiriSocket from the pool.
 var Socket = socketImpl
 
@@ -93,8 +121,25 @@ func (s *NiriSocket) Close() {
 // The action is one of the actions that niri can handle.
 // The supported actions are defined here: https://docs.rs/niri-ipc/latest/niri_ipc/enum.Action.html
 func PerformAction(action actions.Action) bool {
-	socket := Socket()
+	return PerformActionFrom(action, Origin{})
+}
+
+// PerformActionFrom performs the given action like PerformAction, telling where it came from for the dry-run output.
+func PerformActionFrom(action actions.Action, origin Origin) bool {
 	name := action.GetName()
+	if DryRun != nil {
+		return printAction(action, origin)
+	}
+	// The nirimgr actions are performed by nirimgr itself, so they're not sent to niri.
+	if actions.IsNirimgrAction(name) {
+		slog.Debug("PerformAction", "nirimgrAction", name)
+		if err := actions.PerformNirimgrAction(action); err != nil {
+			slog.Error("Could not perform nirimgr action", "name", name, "error", err.Error())
+			return false
+		}
+		return true
+	}
+	socket := Socket()
 
 	// Convert the action to a map.
 	actionData, err := structToMap(action)
@@ -120,6 +165,39 @@ func PerformAction(action actions.Action) bool {
 	return true
 }
 
+// SkipAction tells in the dry-run output that the action wasn't performed, since its condition didn't hold.
+func SkipAction(name string, origin Origin) {
+	if DryRun == nil {
+		return
+	}
+	if _, err := fmt.Fprintf(DryRun, "# skipped %s (%s)\n", name, origin); err != nil {
+		slog.Error("Could not print action", "error", err.Error())
+	}
+}
+
+// printAction prints the action to DryRun as it would be sent to niri, followed by its origin.
+func printAction(action actions.Action, origin Origin) bool {
+	actionData, err := structToMap(action)
+	if err != nil {
+		slog.Error("Could not convert action to map", "error", err.Error())
+		return false
+	}
+	data, err := structToString(actionData)
+	if err != nil {
+		slog.Error("Could not convert action to string", "error", err.Error())
+		return false
+	}
+	line := action.GetName() + " " + data
+	if origin := origin.String(); origin != "" {
+		line += " # " + origin
+	}
+	if _, err := fmt.Fprintln(DryRun, line); err != nil {
+		slog.Error("Could not print action", "error", err.Error())
+		return false
+	}
+	return true
+}
+
 // PerformRequest sends a simple request to the niri socket.
 //
 // The request is one of the requests that niri can handle.
@@ -205,6 +283,21 @@ func ListOutputs() ([]*models.Output, error) {
 	return outputs, nil
 }
 
+// ListLayers returns the current list of layer-shell surfaces from Niri IPC.
+func ListLayers() ([]*models.LayerSurface, error) {
+	response, err := PerformRequest(models.Layers)
+	if err != nil {
+		return nil, err
+	}
+	resp := <-response
+
+	var layers []*models.LayerSurface
+	if err := json.Unmarshal(resp.Ok["Layers"], &layers); err != nil {
+		return nil, err
+	}
+	return layers, nil
+}
+
 // structToMap converts a go struct to a map.
 func structToMap(a any) (map[string]any, error) {
 	var m map[string]any
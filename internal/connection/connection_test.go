@@ -2,12 +2,14 @@
 package connection
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"net"
 	"testing"
 	"time"
 
+	"github.com/soderluk/nirimgr/actions"
 	"github.com/soderluk/nirimgr/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -112,6 +114,56 @@ func TestPerformAction(t *testing.T) {
 	assert.True(t, res)
 }
 
+func TestPerformActionNirimgrAction(t *testing.T) {
+	origSocket := Socket
+	defer func() { Socket = origSocket }()
+
+	// The nirimgr actions must not be sent to niri.
+	Socket = func() *NiriSocket {
+		t.Fatal("nirimgr action was sent to niri")
+		return nil
+	}
+
+	assert.True(t, PerformAction(actions.Sleep{AName: actions.AName{Name: "Sleep"}, Duration: "1ms"}))
+	assert.False(t, PerformAction(actions.Sleep{AName: actions.AName{Name: "Sleep"}, Duration: "soon"}))
+}
+
+func TestPerformActionDryRun(t *testing.T) {
+	origSocket := Socket
+	defer func() { Socket = origSocket }()
+	var output bytes.Buffer
+	DryRun = &output
+	defer func() { DryRun = nil }()
+
+	// Neither the niri nor the nirimgr actions are performed.
+	Socket = func() *NiriSocket {
+		t.Fatal("dry-run action was sent to niri")
+		return nil
+	}
+
+	assert.True(t, PerformAction(actions.FocusWindow{AName: actions.AName{Name: "FocusWindow"}, ID: 3}))
+	assert.True(t, PerformAction(actions.Sleep{AName: actions.AName{Name: "Sleep"}, Duration: "1h"}))
+	assert.Equal(t, "FocusWindow {\"id\":3}\nSleep {\"duration\":\"1h\"}\n", output.String())
+}
+
+func TestPerformActionFromDryRun(t *testing.T) {
+	var output bytes.Buffer
+	DryRun = &output
+	defer func() { DryRun = nil }()
+
+	origin := Origin{Source: "rule 1 (window)", When: "model.IsFloating", Result: true}
+	assert.True(t, PerformActionFrom(actions.CenterWindow{AName: actions.AName{Name: "CenterWindow"}, ID: 2}, origin))
+	SkipAction("CenterWindow", Origin{Source: "event WindowFocusChanged", When: "model.ID == 1"})
+	assert.Equal(t, `CenterWindow {"id":2} # rule 1 (window), when "model.IsFloating" is true
+# skipped CenterWindow (event WindowFocusChanged, when "model.ID == 1" is false)
+`, output.String())
+
+	// Nothing is printed without a dry-run.
+	DryRun = nil
+	SkipAction("CenterWindow", origin)
+	assert.Equal(t, 2, bytes.Count(output.Bytes(), []byte("\n")))
+}
+
 func TestPerformRequest(t *testing.T) {
 	origSocket := Socket
 	defer func() { Socket = origSocket }()
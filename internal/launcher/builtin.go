@@ -0,0 +1,302 @@
+package launcher
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BuiltinLauncher is the launcher name for the built-in terminal picker.
+const BuiltinLauncher = "builtin"
+
+// maxVisible is the maximum number of entries the built-in picker shows at once.
+const maxVisible = 10
+
+// Builtin is an interactive picker running in the terminal.
+//
+// It can be used when no graphical launcher is installed, or e.g. over SSH. Typing filters
+// the entries with a fuzzy match, the arrow keys (or Ctrl-P/Ctrl-N) move the selection,
+// Enter selects and Escape or Ctrl-C cancels.
+type Builtin struct {
+	// TTY is the path to the terminal device. Defaults to /dev/tty.
+	TTY string
+}
+
+// Select opens the picker in the terminal and returns the selected entry.
+func (b *Builtin) Select(entries []Entry) (Entry, error) {
+	if len(entries) == 0 {
+		return Entry{}, fmt.Errorf("no entries to select from")
+	}
+	path := b.TTY
+	if path == "" {
+		path = "/dev/tty"
+	}
+	tty, err := os.OpenFile(path, os.O_RDWR, 0) // #nosec G304
+	if err != nil {
+		return Entry{}, fmt.Errorf("could not open terminal for the builtin picker: %w", err)
+	}
+	defer func() { _ = tty.Close() }()
+
+	restore, err := makeRaw(int(tty.Fd()))
+	if err != nil {
+		return Entry{}, fmt.Errorf("could not set terminal to raw mode: %w", err)
+	}
+	defer func() { _ = restore() }()
+
+	p := newPicker(entries)
+	p.width = terminalWidth(int(tty.Fd()))
+	return runPicker(p, tty, tty)
+}
+
+// runPicker reads keys from r and renders the picker to w until an entry is selected or the picker is cancelled.
+func runPicker(p *picker, r io.Reader, w io.Writer) (Entry, error) {
+	reader := bufio.NewReader(r)
+	defer p.clear(w)
+
+	for {
+		p.render(w)
+		k, err := readKey(reader)
+		if err != nil {
+			return Entry{}, err
+		}
+		switch p.handleKey(k) {
+		case stateSelected:
+			return p.entries[p.matches[p.cursor]], nil
+		case stateCancelled:
+			return Entry{}, ErrCancelled
+		}
+	}
+}
+
+// keyType is the type of key pressed in the picker.
+type keyType int
+
+const (
+	keyRune keyType = iota
+	keyEnter
+	keyCancel
+	keyUp
+	keyDown
+	keyBackspace
+	keyClear
+	keyUnknown
+)
+
+// key is a single decoded key press.
+type key struct {
+	typ keyType
+	r   rune
+}
+
+// readKey decodes the next key press from the reader.
+//
+// A lone escape cancels the picker, while escape sequences (e.g. the arrow keys) arrive
+// in a single read, so they are already buffered when we see the escape.
+func readKey(r *bufio.Reader) (key, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+	switch c {
+	case '\r', '\n':
+		return key{typ: keyEnter}, nil
+	case 0x03: // Ctrl-C
+		return key{typ: keyCancel}, nil
+	case 0x7f, 0x08: // Backspace, Ctrl-H
+		return key{typ: keyBackspace}, nil
+	case 0x15: // Ctrl-U
+		return key{typ: keyClear}, nil
+	case 0x10: // Ctrl-P
+		return key{typ: keyUp}, nil
+	case 0x0e: // Ctrl-N
+		return key{typ: keyDown}, nil
+	case 0x1b:
+		if r.Buffered() == 0 {
+			return key{typ: keyCancel}, nil
+		}
+		next, _, err := r.ReadRune()
+		if err != nil {
+			return key{}, err
+		}
+		if next != '[' && next != 'O' {
+			return key{typ: keyUnknown}, nil
+		}
+		code, _, err := r.ReadRune()
+		if err != nil {
+			return key{}, err
+		}
+		switch code {
+		case 'A':
+			return key{typ: keyUp}, nil
+		case 'B':
+			return key{typ: keyDown}, nil
+		}
+		return key{typ: keyUnknown}, nil
+	}
+	if unicode.IsPrint(c) {
+		return key{typ: keyRune, r: c}, nil
+	}
+	return key{typ: keyUnknown}, nil
+}
+
+// pickerState is the state of the picker after handling a key.
+type pickerState int
+
+const (
+	stateRunning pickerState = iota
+	stateSelected
+	stateCancelled
+)
+
+// picker contains the state of the built-in picker.
+type picker struct {
+	entries []Entry
+	query   []rune
+	// matches contains the indices of the entries matching the query, best match first.
+	matches []int
+	// cursor is the index of the selected entry in matches.
+	cursor int
+	// width is the width of the terminal the labels are cut to, so they don't wrap. Zero if not known.
+	width int
+}
+
+// newPicker returns a picker showing all the given entries.
+func newPicker(entries []Entry) *picker {
+	p := &picker{entries: entries}
+	p.filter()
+	return p
+}
+
+// handleKey updates the picker state with the given key.
+func (p *picker) handleKey(k key) pickerState {
+	switch k.typ {
+	case keyEnter:
+		if len(p.matches) > 0 {
+			return stateSelected
+		}
+	case keyCancel:
+		return stateCancelled
+	case keyUp:
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case keyDown:
+		if p.cursor < len(p.matches)-1 {
+			p.cursor++
+		}
+	case keyBackspace:
+		if len(p.query) > 0 {
+			p.query = p.query[:len(p.query)-1]
+			p.filter()
+		}
+	case keyClear:
+		p.query = nil
+		p.filter()
+	case keyRune:
+		p.query = append(p.query, k.r)
+		p.filter()
+	}
+	return stateRunning
+}
+
+// filter updates the matches for the current query, and resets the cursor.
+func (p *picker) filter() {
+	type scored struct {
+		idx   int
+		score int
+	}
+	var results []scored
+	for idx, entry := range p.entries {
+		if score, ok := fuzzyScore(string(p.query), entry.Label); ok {
+			results = append(results, scored{idx: idx, score: score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].score > results[j].score
+	})
+	p.matches = make([]int, len(results))
+	for i, result := range results {
+		p.matches[i] = result.idx
+	}
+	p.cursor = 0
+}
+
+// render draws the prompt and the visible matches, leaving the terminal cursor on the prompt.
+func (p *picker) render(w io.Writer) {
+	var b strings.Builder
+	b.WriteString("\r\x1b[J> ")
+	b.WriteString(string(p.query))
+
+	// Scroll the visible window so the cursor is always shown.
+	start := 0
+	if p.cursor >= maxVisible {
+		start = p.cursor - maxVisible + 1
+	}
+	end := min(start+maxVisible, len(p.matches))
+	for i := start; i < end; i++ {
+		label := truncate(sanitize(p.entries[p.matches[i]].Label), p.width-2)
+		if i == p.cursor {
+			fmt.Fprintf(&b, "\r\n\x1b[7m> %s\x1b[0m", label)
+		} else {
+			fmt.Fprintf(&b, "\r\n  %s", label)
+		}
+	}
+	if rendered := end - start; rendered > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", rendered)
+	}
+	fmt.Fprintf(&b, "\r\x1b[%dC", len(p.query)+2)
+	_, _ = io.WriteString(w, b.String())
+}
+
+// truncate cuts the label to the width, marking the cut with an ellipsis. Zero or less keeps the label as it is.
+func truncate(label string, width int) string {
+	runes := []rune(label)
+	if width <= 0 || len(runes) <= width {
+		return label
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// clear removes the picker from the terminal.
+func (p *picker) clear(w io.Writer) {
+	_, _ = io.WriteString(w, "\r\x1b[J")
+}
+
+// fuzzyScore returns the score of the label for the query, and whether the label matches at all.
+//
+// All the characters of the query must appear in the label in the same order (case-insensitive).
+// Consecutive matches and matches at the start of a word score higher.
+func fuzzyScore(query, label string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+	q := []rune(strings.ToLower(query))
+	l := []rune(strings.ToLower(label))
+
+	score := 0
+	qi := 0
+	prevMatched := false
+	for li := 0; li < len(l) && qi < len(q); li++ {
+		if l[li] != q[qi] {
+			prevMatched = false
+			continue
+		}
+		score++
+		if prevMatched {
+			score += 3
+		}
+		if li == 0 || !unicode.IsLetter(l[li-1]) && !unicode.IsDigit(l[li-1]) {
+			score += 3
+		}
+		prevMatched = true
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score, true
+}
@@ -0,0 +1,119 @@
+package launcher
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	_, ok := fuzzyScore("", "anything")
+	assert.True(t, ok)
+
+	_, ok = fuzzyScore("trm", "Terminal")
+	assert.True(t, ok)
+
+	_, ok = fuzzyScore("mrt", "Terminal")
+	assert.False(t, ok)
+
+	// Consecutive matches at the start of a word score higher than scattered matches.
+	prefix, _ := fuzzyScore("fire", "Firefox")
+	scattered, _ := fuzzyScore("fire", "foo in rare elf")
+	assert.Greater(t, prefix, scattered)
+}
+
+func TestReadKey(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected key
+	}{
+		{"\r", key{typ: keyEnter}},
+		{"\x03", key{typ: keyCancel}},
+		{"\x1b", key{typ: keyCancel}},
+		{"\x1b[A", key{typ: keyUp}},
+		{"\x1bOB", key{typ: keyDown}},
+		{"\x10", key{typ: keyUp}},
+		{"\x0e", key{typ: keyDown}},
+		{"\x7f", key{typ: keyBackspace}},
+		{"\x15", key{typ: keyClear}},
+		{"ä", key{typ: keyRune, r: 'ä'}},
+		{"\x1b[C", key{typ: keyUnknown}},
+	}
+	for _, test := range tests {
+		k, err := readKey(bufio.NewReader(strings.NewReader(test.input)))
+		assert.NoError(t, err, test.input)
+		assert.Equal(t, test.expected, k, "%q", test.input)
+	}
+}
+
+func TestPickerHandleKey(t *testing.T) {
+	p := newPicker([]Entry{
+		{ID: 1, Label: "Firefox"},
+		{ID: 2, Label: "Terminal"},
+		{ID: 3, Label: "Telegram"},
+	})
+	assert.Equal(t, []int{0, 1, 2}, p.matches)
+
+	assert.Equal(t, stateRunning, p.handleKey(key{typ: keyUp}))
+	assert.Equal(t, 0, p.cursor)
+	p.handleKey(key{typ: keyDown})
+	p.handleKey(key{typ: keyDown})
+	p.handleKey(key{typ: keyDown})
+	assert.Equal(t, 2, p.cursor)
+
+	p.handleKey(key{typ: keyRune, r: 't'})
+	p.handleKey(key{typ: keyRune, r: 'e'})
+	assert.Equal(t, []int{1, 2}, p.matches)
+	assert.Equal(t, 0, p.cursor)
+
+	p.handleKey(key{typ: keyRune, r: 'g'})
+	assert.Equal(t, []int{2}, p.matches)
+	p.handleKey(key{typ: keyBackspace})
+	assert.Equal(t, []int{1, 2}, p.matches)
+
+	p.handleKey(key{typ: keyRune, r: 'x'})
+	p.handleKey(key{typ: keyRune, r: 'x'})
+	assert.Empty(t, p.matches)
+	assert.Equal(t, stateRunning, p.handleKey(key{typ: keyEnter}))
+
+	p.handleKey(key{typ: keyClear})
+	assert.Len(t, p.matches, 3)
+	assert.Equal(t, stateSelected, p.handleKey(key{typ: keyEnter}))
+	assert.Equal(t, stateCancelled, p.handleKey(key{typ: keyCancel}))
+}
+
+func TestRunPicker(t *testing.T) {
+	entries := []Entry{
+		{ID: 1, Label: "Firefox"},
+		{ID: 2, Label: "Terminal"},
+		{ID: 3, Label: "Telegram"},
+	}
+	var out bytes.Buffer
+	entry, err := runPicker(newPicker(entries), strings.NewReader("te\x1b[B\r"), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), entry.ID)
+	assert.Contains(t, out.String(), "Telegram")
+
+	_, err = runPicker(newPicker(entries), strings.NewReader("\x1b"), &out)
+	assert.ErrorIs(t, err, ErrCancelled)
+
+	_, err = runPicker(newPicker(entries), strings.NewReader("te"), &out)
+	assert.Error(t, err)
+}
+
+func TestRenderTruncate(t *testing.T) {
+	p := newPicker([]Entry{{ID: 1, Label: "Firefox — a very long window title"}, {ID: 2, Label: "Terminal"}})
+	p.width = 12
+	var out bytes.Buffer
+	p.render(&out)
+	assert.Contains(t, out.String(), "> Firefox —…")
+	assert.NotContains(t, out.String(), "long")
+	assert.Contains(t, out.String(), "  Terminal")
+
+	assert.Equal(t, "Terminal", truncate("Terminal", 8))
+	assert.Equal(t, "Termin…", truncate("Terminal", 7))
+	assert.Equal(t, "Terminal", truncate("Terminal", 0))
+}
@@ -0,0 +1,224 @@
+// Package launcher lets the user pick one entry from a list using an external launcher.
+//
+// The launcher is executed directly with an argument vector, never through a shell,
+// so window titles or other entry labels can't break or inject into the command.
+// The entries are written to the launcher's stdin one per line, and the selection is
+// mapped back to the entry either by the index the launcher prints, or by the exact
+// line it echoes back.
+//
+// Presets are provided for fuzzel, rofi, wofi, tofi, bemenu and dmenu. In addition,
+// "builtin" is an interactive picker running in the terminal. Dismissing the launcher
+// (e.g. pressing Escape) returns ErrCancelled, which callers should treat as a clean no-op.
+package launcher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/soderluk/nirimgr/config"
+)
+
+// ErrCancelled is returned when the launcher was dismissed without selecting anything.
+var ErrCancelled = errors.New("selection cancelled")
+
+// Entry is a single selectable item shown in the launcher.
+type Entry struct {
+	// ID is a stable identifier for the entry, e.g. the window ID.
+	ID uint64
+	// Label is the text shown to the user.
+	Label string
+}
+
+// Launcher selects one entry from the given entries.
+type Launcher interface {
+	Select(entries []Entry) (Entry, error)
+}
+
+// Preset describes how to run a known launcher in dmenu mode.
+type Preset struct {
+	// Args are the default arguments passed to the launcher.
+	Args []string
+	// IndexOutput tells that the launcher prints the 0-based index of the selected line,
+	// instead of the line itself.
+	IndexOutput bool
+	// IndexArgs are the arguments that make the launcher print the index, e.g. "--index".
+	// The user supplied arguments print the index only if they contain these in order.
+	IndexArgs []string
+}
+
+// Presets contains the supported launchers, keyed by their executable name.
+var Presets = map[string]Preset{
+	"fuzzel": {Args: []string{"--dmenu", "--index", "--width", "50"}, IndexOutput: true, IndexArgs: []string{"--index"}},
+	"rofi":   {Args: []string{"-dmenu", "-i", "-format", "i"}, IndexOutput: true, IndexArgs: []string{"-format", "i"}},
+	"wofi":   {Args: []string{"--dmenu", "--insensitive"}},
+	"tofi":   {Args: []string{}},
+	"bemenu": {Args: []string{"-i"}},
+	"dmenu":  {Args: []string{"-i"}},
+}
+
+// DefaultLauncher is used when no launcher has been configured.
+const DefaultLauncher = "fuzzel"
+
+// execCommand is a variable that points to exec.Command, allowing us to mock it in tests.
+var execCommand = exec.Command
+
+// lookPath is a variable that points to exec.LookPath, allowing us to mock it in tests.
+var lookPath = exec.LookPath
+
+// stdinIsTerminal checks if stdin is a terminal, allowing us to mock it in tests.
+var stdinIsTerminal = func() bool { return isTerminal(int(os.Stdin.Fd())) }
+
+// Command runs an external launcher binary.
+type Command struct {
+	// Path is the path or name of the launcher executable.
+	Path string
+	// Args are the arguments passed to the launcher.
+	Args []string
+	// IndexOutput tells that the launcher prints the index of the selected line.
+	IndexOutput bool
+}
+
+// New returns a launcher for the given name and arguments.
+//
+// The name can either be a preset name (e.g. "rofi"), or a path to the launcher executable.
+// The preset is picked from the base name of the path, so "/usr/bin/fuzzel" uses the fuzzel preset.
+// If args is nil, the preset's default arguments are used. Otherwise the launcher is expected to print
+// the index only if args contain the preset's index arguments, and the selected line otherwise.
+func New(name string, args []string) (Launcher, error) {
+	if name == "" {
+		name = DefaultLauncher
+	}
+	if name == BuiltinLauncher {
+		return &Builtin{}, nil
+	}
+	preset, known := Presets[filepath.Base(name)]
+	if args == nil {
+		if !known {
+			return nil, fmt.Errorf("unknown launcher '%s', please configure launcherArgs", name)
+		}
+		return &Command{Path: name, Args: preset.Args, IndexOutput: preset.IndexOutput}, nil
+	}
+	indexOutput := preset.IndexOutput && containsArgs(args, preset.IndexArgs)
+	return &Command{Path: name, Args: args, IndexOutput: indexOutput}, nil
+}
+
+// containsArgs checks if the args contain the wanted arguments next to each other, in order.
+func containsArgs(args, wanted []string) bool {
+	if len(wanted) == 0 {
+		return false
+	}
+	for idx := 0; idx+len(wanted) <= len(args); idx++ {
+		if slices.Equal(args[idx:idx+len(wanted)], wanted) {
+			return true
+		}
+	}
+	return false
+}
+
+// Default returns the launcher configured in the config file.
+//
+// The arguments are taken from "launcherArgs" if set, otherwise "launcherOptions" is split on
+// whitespace. If neither is set, the preset's default arguments are used.
+// If the configured launcher is not installed, we fall back to the built-in terminal picker when run
+// in a terminal. Otherwise, e.g. when run from a niri key-bind, the launcher not being found is an error.
+func Default() (Launcher, error) {
+	name := config.Config.Launcher
+	if name == "" {
+		name = DefaultLauncher
+	}
+	if name != BuiltinLauncher {
+		if _, err := lookPath(name); err != nil {
+			if !stdinIsTerminal() {
+				return nil, fmt.Errorf("launcher %s not found: %w", name, err)
+			}
+			slog.Warn("Launcher not found, falling back to the builtin picker", "launcher", name, "error", err.Error())
+			return &Builtin{}, nil
+		}
+	}
+	var args []string
+	if len(config.Config.LauncherArgs) > 0 {
+		args = config.Config.LauncherArgs
+	} else if config.Config.LauncherOptions != "" {
+		args = strings.Fields(config.Config.LauncherOptions)
+	}
+	return New(name, args)
+}
+
+// Select runs the launcher with the entries on stdin and returns the selected entry.
+func (c *Command) Select(entries []Entry) (Entry, error) {
+	if len(entries) == 0 {
+		return Entry{}, errors.New("no entries to select from")
+	}
+	lines := make([]string, len(entries))
+	for idx, entry := range entries {
+		lines[idx] = c.line(entry)
+	}
+
+	cmd := execCommand(c.Path, c.Args...)
+	cmd.Stdin = strings.NewReader(strings.Join(lines, "\n") + "\n")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	output := strings.TrimRight(stdout.String(), "\r\n")
+	if output == "" {
+		var exitErr *exec.ExitError
+		if err == nil || errors.As(err, &exitErr) {
+			return Entry{}, ErrCancelled
+		}
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("could not run launcher '%s': %w: %s", c.Path, err, stderr.String())
+	}
+
+	idx, err := c.parseSelection(output, lines)
+	if err != nil {
+		return Entry{}, err
+	}
+	return entries[idx], nil
+}
+
+// line renders the entry as a single line for the launcher.
+//
+// Launchers that print the selected index only need the label. For the others we prefix
+// the ID, so entries with the same label can still be told apart.
+func (c *Command) line(entry Entry) string {
+	label := sanitize(entry.Label)
+	if c.IndexOutput {
+		return label
+	}
+	return fmt.Sprintf("%d: %s", entry.ID, label)
+}
+
+// parseSelection maps the launcher output back to the index of the selected line.
+func (c *Command) parseSelection(output string, lines []string) (int, error) {
+	if c.IndexOutput {
+		if idx, err := strconv.Atoi(strings.TrimSpace(output)); err == nil {
+			if idx < 0 || idx >= len(lines) {
+				return 0, fmt.Errorf("launcher returned index %d out of range", idx)
+			}
+			return idx, nil
+		}
+	}
+	for idx, line := range lines {
+		if line == output {
+			return idx, nil
+		}
+	}
+	return 0, fmt.Errorf("launcher returned unknown selection '%s'", output)
+}
+
+// sanitize makes sure the label fits on a single line.
+func sanitize(label string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(label)
+}
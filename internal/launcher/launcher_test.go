@@ -0,0 +1,214 @@
+package launcher
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/soderluk/nirimgr/config"
+	"github.com/soderluk/nirimgr/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var testEntries = []Entry{
+	{ID: 10, Label: "Terminal"},
+	{ID: 20, Label: `evil "$(rm -rf ~)" title`},
+	{ID: 30, Label: "multi\nline"},
+}
+
+// TestHelperProcess acts as the launcher binary.
+//
+// It echoes the stdin to the file in LAUNCHER_STDIN_FILE, then prints LAUNCHER_OUTPUT and
+// exits with LAUNCHER_EXIT.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_TEST_HELPER_PROCESS") != "1" {
+		return
+	}
+	stdin, _ := io.ReadAll(os.Stdin)
+	if f := os.Getenv("LAUNCHER_STDIN_FILE"); f != "" {
+		_ = os.WriteFile(f, stdin, 0o600)
+	}
+	fmt.Print(os.Getenv("LAUNCHER_OUTPUT"))
+	if os.Getenv("LAUNCHER_EXIT") != "" {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// mockLauncher makes execCommand run the helper process with the given output and exit status.
+//
+// Returns a function to read the captured stdin, and the captured command and args.
+func mockLauncher(t *testing.T, output string, fail bool) (func() string, *[]string) {
+	t.Helper()
+	original := execCommand
+	t.Cleanup(func() { execCommand = original })
+
+	stdinFile := t.TempDir() + "/stdin"
+	captured := []string{}
+	execCommand = func(command string, args ...string) *exec.Cmd {
+		captured = append([]string{command}, args...)
+		cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess", "--")
+		cmd.Env = []string{
+			"GO_TEST_HELPER_PROCESS=1",
+			"LAUNCHER_STDIN_FILE=" + stdinFile,
+			"LAUNCHER_OUTPUT=" + output,
+		}
+		if fail {
+			cmd.Env = append(cmd.Env, "LAUNCHER_EXIT=1")
+		}
+		return cmd
+	}
+	return func() string {
+		b, _ := os.ReadFile(stdinFile) // #nosec G304
+		return string(b)
+	}, &captured
+}
+
+func TestNew(t *testing.T) {
+	l, err := New("", nil)
+	assert.NoError(t, err)
+	c := l.(*Command)
+	assert.Equal(t, "fuzzel", c.Path)
+	assert.Equal(t, Presets["fuzzel"].Args, c.Args)
+	assert.True(t, c.IndexOutput)
+
+	l, err = New("/usr/bin/wofi", nil)
+	assert.NoError(t, err)
+	c = l.(*Command)
+	assert.Equal(t, "/usr/bin/wofi", c.Path)
+	assert.False(t, c.IndexOutput)
+
+	l, err = New("/usr/bin/rofi", []string{"-dmenu"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-dmenu"}, l.(*Command).Args)
+	assert.False(t, l.(*Command).IndexOutput)
+
+	// The user supplied arguments print the index only with the preset's index arguments.
+	l, err = New("rofi", []string{"-dmenu", "-format", "i"})
+	assert.NoError(t, err)
+	assert.True(t, l.(*Command).IndexOutput)
+	l, err = New("fuzzel", []string{"-d", "--index"})
+	assert.NoError(t, err)
+	assert.True(t, l.(*Command).IndexOutput)
+	l, err = New("fuzzel", []string{"-d", "-w", "50"})
+	assert.NoError(t, err)
+	assert.False(t, l.(*Command).IndexOutput)
+
+	_, err = New("mylauncher", nil)
+	assert.Error(t, err)
+
+	l, err = New("mylauncher", []string{"--pick"})
+	assert.NoError(t, err)
+	assert.False(t, l.(*Command).IndexOutput)
+}
+
+func TestDefault(t *testing.T) {
+	original := config.Config
+	originalLookPath := lookPath
+	defer func() {
+		config.Config = original
+		lookPath = originalLookPath
+	}()
+	lookPath = func(file string) (string, error) { return file, nil }
+
+	config.Config = &models.Config{Launcher: "/usr/bin/fuzzel", LauncherOptions: "-d -w 50"}
+	l, err := Default()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-d", "-w", "50"}, l.(*Command).Args)
+	assert.False(t, l.(*Command).IndexOutput)
+
+	config.Config = &models.Config{Launcher: "rofi", LauncherOptions: "-d", LauncherArgs: []string{"-dmenu", "-p", "Pick a window"}}
+	l, err = Default()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-dmenu", "-p", "Pick a window"}, l.(*Command).Args)
+
+	config.Config = &models.Config{Launcher: "builtin"}
+	l, err = Default()
+	assert.NoError(t, err)
+	assert.IsType(t, &Builtin{}, l)
+
+	// Fall back to the builtin picker if the launcher isn't installed, only in a terminal.
+	originalStdinIsTerminal := stdinIsTerminal
+	defer func() { stdinIsTerminal = originalStdinIsTerminal }()
+	lookPath = func(file string) (string, error) { return "", exec.ErrNotFound }
+	config.Config = &models.Config{Launcher: "rofi"}
+	stdinIsTerminal = func() bool { return true }
+	l, err = Default()
+	assert.NoError(t, err)
+	assert.IsType(t, &Builtin{}, l)
+
+	stdinIsTerminal = func() bool { return false }
+	_, err = Default()
+	assert.ErrorContains(t, err, "launcher rofi not found")
+	assert.ErrorIs(t, err, exec.ErrNotFound)
+}
+
+func TestSelectIndexOutput(t *testing.T) {
+	stdin, captured := mockLauncher(t, "1\n", false)
+	l, _ := New("fuzzel", nil)
+
+	entry, err := l.Select(testEntries)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), entry.ID)
+	assert.Equal(t, append([]string{"fuzzel"}, Presets["fuzzel"].Args...), *captured)
+	assert.Equal(t, "Terminal\nevil \"$(rm -rf ~)\" title\nmulti line\n", stdin())
+}
+
+func TestSelectLineOutput(t *testing.T) {
+	stdin, _ := mockLauncher(t, "30: multi line\n", false)
+	l, _ := New("dmenu", nil)
+
+	entry, err := l.Select(testEntries)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(30), entry.ID)
+	assert.True(t, strings.HasPrefix(stdin(), "10: Terminal\n"))
+}
+
+func TestSelectIndexLauncherPrintingLine(t *testing.T) {
+	// fuzzel configured without --index prints the line itself, with the ID to tell the same labels apart.
+	entries := []Entry{{ID: 10, Label: "2"}, {ID: 20, Label: "2"}, {ID: 30, Label: "Terminal"}}
+	stdin, _ := mockLauncher(t, "20: 2\n", false)
+	l, _ := New("fuzzel", []string{"-d", "-w", "50"})
+
+	entry, err := l.Select(entries)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), entry.ID)
+	assert.Equal(t, "10: 2\n20: 2\n30: Terminal\n", stdin())
+}
+
+func TestSelectCancelled(t *testing.T) {
+	mockLauncher(t, "", true)
+	l, _ := New("rofi", nil)
+	_, err := l.Select(testEntries)
+	assert.ErrorIs(t, err, ErrCancelled)
+
+	mockLauncher(t, "", false)
+	_, err = l.Select(testEntries)
+	assert.ErrorIs(t, err, ErrCancelled)
+}
+
+func TestSelectInvalidOutput(t *testing.T) {
+	mockLauncher(t, "7\n", false)
+	l, _ := New("rofi", nil)
+	_, err := l.Select(testEntries)
+	assert.ErrorContains(t, err, "out of range")
+
+	mockLauncher(t, "something else\n", false)
+	l, _ = New("bemenu", nil)
+	_, err = l.Select(testEntries)
+	assert.ErrorContains(t, err, "unknown selection")
+
+	mockLauncher(t, "0\n", true)
+	_, err = l.Select(testEntries)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrCancelled)
+}
+
+func TestSelectNoEntries(t *testing.T) {
+	l, _ := New("fuzzel", nil)
+	_, err := l.Select(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package launcher
+
+import "errors"
+
+// makeRaw is not supported outside of linux, since niri only runs on linux.
+func makeRaw(_ int) (func() error, error) {
+	return nil, errors.New("the builtin picker is only supported on linux")
+}
+
+// terminalWidth is not known outside of linux.
+func terminalWidth(_ int) int {
+	return 0
+}
+
+// isTerminal reports false outside of linux, since the builtin picker isn't supported.
+func isTerminal(_ int) bool {
+	return false
+}
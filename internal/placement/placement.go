@@ -0,0 +1,461 @@
+// Package placement computes the positions and sizes of floating windows.
+//
+// The computations are pure, i.e. they only work on the geometry given to them, so the commands
+// fetch the windows, outputs and layers from niri and turn the results into actions.
+//
+// All the rectangles are in the workspace view coordinates, i.e. relative to the top-left corner
+// of the output, same as WindowLayout.TilePosInWorkspaceView.
+package placement
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/soderluk/nirimgr/models"
+)
+
+// Rect is a rectangle in logical pixels.
+type Rect struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// Positions lists the positions supported by Place.
+var Positions = []string{
+	"top-left", "top", "top-right",
+	"left", "center", "right",
+	"bottom-left", "bottom", "bottom-right",
+	"left-half", "right-half", "top-half", "bottom-half",
+	"left-third", "center-third", "right-third",
+	"left-two-thirds", "right-two-thirds",
+}
+
+// Directions lists the directions supported by Move.
+var Directions = []string{"left", "right", "up", "down"}
+
+// Arrangements are the supported arrangements for all the floating windows on a workspace.
+var Arrangements = []string{"cascade", "tile", "stack"}
+
+// CascadeStep is the offset in pixels between the windows in the cascade arrangement.
+const CascadeStep = 32
+
+// Tile returns the rectangle of the window's tile, including the borders.
+func Tile(layout models.WindowLayout) (Rect, error) {
+	if len(layout.TilePosInWorkspaceView) != 2 {
+		return Rect{}, errors.New("window has no position in the workspace view")
+	}
+	if len(layout.TileSize) != 2 {
+		return Rect{}, errors.New("window has no tile size")
+	}
+	return Rect{
+		X:      layout.TilePosInWorkspaceView[0],
+		Y:      layout.TilePosInWorkspaceView[1],
+		Width:  layout.TileSize[0],
+		Height: layout.TileSize[1],
+	}, nil
+}
+
+// WindowSize returns the size of the window for the given tile size.
+//
+// The window is inside the tile at WindowOffsetInTile, with the same decorations (e.g. borders) on the opposite sides.
+func WindowSize(layout models.WindowLayout, tileWidth, tileHeight float64) (int32, int32) {
+	var offsetX, offsetY float64
+	if len(layout.WindowOffsetInTile) == 2 {
+		offsetX = layout.WindowOffsetInTile[0]
+		offsetY = layout.WindowOffsetInTile[1]
+	}
+	return int32(tileWidth - 2*offsetX), int32(tileHeight - 2*offsetY)
+}
+
+// ExclusiveZones returns the configured exclusive zones of the layer-shell surfaces on the given output.
+//
+// niri doesn't tell the size of the layer-shell surfaces, so the zones are configured by the layer namespace,
+// and only applied when a surface with that namespace is on the output.
+func ExclusiveZones(layers []*models.LayerSurface, output string, zones map[string]models.Insets) []models.Insets {
+	var result []models.Insets
+	seen := make(map[string]struct{})
+	for _, layer := range layers {
+		if layer.Output != output {
+			continue
+		}
+		if _, ok := seen[layer.Namespace]; ok {
+			continue
+		}
+		if zone, ok := zones[layer.Namespace]; ok {
+			result = append(result, zone)
+			seen[layer.Namespace] = struct{}{}
+		}
+	}
+	return result
+}
+
+// WorkingArea returns the area of the output not covered by the exclusive zones.
+func WorkingArea(output models.LogicalOutput, zones []models.Insets) Rect {
+	area := Rect{Width: float64(output.Width), Height: float64(output.Height)}
+	for _, zone := range zones {
+		area.X += zone.Left
+		area.Y += zone.Top
+		area.Width -= zone.Left + zone.Right
+		area.Height -= zone.Top + zone.Bottom
+	}
+	return area
+}
+
+// OutputRect returns the rectangle of the output in the global compositor space.
+//
+// niri reports the logical size with the scale and transform already applied, e.g. a rotated
+// output has its width and height swapped.
+func OutputRect(output models.LogicalOutput) Rect {
+	return Rect{X: float64(output.X), Y: float64(output.Y), Width: float64(output.Width), Height: float64(output.Height)}
+}
+
+// AdjacentOutput returns the nearest output next to the current output in the given direction.
+//
+// The direction is one of left, right, up or down. The outputs are compared in the global compositor space,
+// preferring the outputs overlapping the current output on the other axis.
+func AdjacentOutput(outputs []*models.Output, current *models.Output, direction string) (*models.Output, error) {
+	cur := OutputRect(current.Logical)
+	var best *models.Output
+	var bestDistance, bestOffset float64
+	for _, output := range outputs {
+		if output.Name == current.Name || output.Logical.Width == 0 || output.Logical.Height == 0 {
+			continue
+		}
+		o := OutputRect(output.Logical)
+		var distance, offset float64
+		switch direction {
+		case "left":
+			distance = cur.X - (o.X + o.Width)
+			offset = overlapOffset(cur.Y, cur.Height, o.Y, o.Height)
+		case "right":
+			distance = o.X - (cur.X + cur.Width)
+			offset = overlapOffset(cur.Y, cur.Height, o.Y, o.Height)
+		case "up":
+			distance = cur.Y - (o.Y + o.Height)
+			offset = overlapOffset(cur.X, cur.Width, o.X, o.Width)
+		case "down":
+			distance = o.Y - (cur.Y + cur.Height)
+			offset = overlapOffset(cur.X, cur.Width, o.X, o.Width)
+		default:
+			return nil, fmt.Errorf("invalid direction '%v'", direction)
+		}
+		if distance < 0 {
+			continue
+		}
+		if best == nil || offset < bestOffset || offset == bestOffset && distance < bestDistance {
+			best, bestDistance, bestOffset = output, distance, offset
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no output %v of %v", direction, current.Name)
+	}
+	return best, nil
+}
+
+// overlapOffset returns 0 if the two ranges overlap, otherwise the distance between their centers.
+func overlapOffset(start, length, otherStart, otherLength float64) float64 {
+	if start < otherStart+otherLength && otherStart < start+length {
+		return 0
+	}
+	return math.Abs(start + length/2 - (otherStart + otherLength/2))
+}
+
+// Transfer returns the tile moved from one area to another, keeping its position and size relative to the areas.
+func Transfer(tile, from, to Rect) Rect {
+	scaleX := to.Width / from.Width
+	scaleY := to.Height / from.Height
+	return Rect{
+		X:      to.X + (tile.X-from.X)*scaleX,
+		Y:      to.Y + (tile.Y-from.Y)*scaleY,
+		Width:  tile.Width * scaleX,
+		Height: tile.Height * scaleY,
+	}
+}
+
+// Snap rounds the position of the rectangle to the physical pixel grid of an output with the given scale.
+//
+// With fractional scaling a logical pixel doesn't map to a whole physical pixel, so an unrounded position
+// would make the window contents blurry.
+func Snap(rect Rect, scale float64) Rect {
+	if scale <= 0 {
+		scale = 1
+	}
+	rect.X = math.Round(rect.X*scale) / scale
+	rect.Y = math.Round(rect.Y*scale) / scale
+	return rect
+}
+
+// Place returns the new rectangle of the tile at the given position in the working area.
+//
+// The corners, edges and center keep the size of the tile, while the halves and thirds resize the tile
+// to fill that part of the working area. The gap is kept between the tile and the edges of the working
+// area, and between the parts.
+func Place(position string, area, tile Rect, gap float64) (Rect, error) {
+	left := area.X + gap
+	centerX := area.X + (area.Width-tile.Width)/2
+	right := area.X + area.Width - tile.Width - gap
+	top := area.Y + gap
+	centerY := area.Y + (area.Height-tile.Height)/2
+	bottom := area.Y + area.Height - tile.Height - gap
+
+	switch position {
+	case "top-left":
+		return Rect{left, top, tile.Width, tile.Height}, nil
+	case "top":
+		return Rect{centerX, top, tile.Width, tile.Height}, nil
+	case "top-right":
+		return Rect{right, top, tile.Width, tile.Height}, nil
+	case "left":
+		return Rect{left, centerY, tile.Width, tile.Height}, nil
+	case "center":
+		return Rect{centerX, centerY, tile.Width, tile.Height}, nil
+	case "right":
+		return Rect{right, centerY, tile.Width, tile.Height}, nil
+	case "bottom-left":
+		return Rect{left, bottom, tile.Width, tile.Height}, nil
+	case "bottom":
+		return Rect{centerX, bottom, tile.Width, tile.Height}, nil
+	case "bottom-right":
+		return Rect{right, bottom, tile.Width, tile.Height}, nil
+	case "left-half":
+		return columns(area, gap, 2, 0, 1), nil
+	case "right-half":
+		return columns(area, gap, 2, 1, 1), nil
+	case "top-half":
+		return rows(area, gap, 2, 0, 1), nil
+	case "bottom-half":
+		return rows(area, gap, 2, 1, 1), nil
+	case "left-third":
+		return columns(area, gap, 3, 0, 1), nil
+	case "center-third":
+		return columns(area, gap, 3, 1, 1), nil
+	case "right-third":
+		return columns(area, gap, 3, 2, 1), nil
+	case "left-two-thirds":
+		return columns(area, gap, 3, 0, 2), nil
+	case "right-two-thirds":
+		return columns(area, gap, 3, 1, 2), nil
+	}
+	return Rect{}, fmt.Errorf("invalid position '%v'", position)
+}
+
+// Move returns the new rectangle of the tile moved to the edge of the working area in the given direction.
+//
+// Only the position in that direction changes, the tile keeps its size and the other coordinate.
+func Move(direction string, area, tile Rect, gap float64) (Rect, error) {
+	moved := tile
+	switch direction {
+	case "left":
+		moved.X = area.X + gap
+	case "right":
+		moved.X = area.X + area.Width - tile.Width - gap
+	case "up":
+		moved.Y = area.Y + gap
+	case "down":
+		moved.Y = area.Y + area.Height - tile.Height - gap
+	default:
+		return Rect{}, fmt.Errorf("invalid direction '%v'", direction)
+	}
+	return moved, nil
+}
+
+// columns returns the rectangle spanning span columns starting from the given column,
+// when the area is split into n columns.
+func columns(area Rect, gap float64, n, column, span int) Rect {
+	return Cell(area, gap, n, 1, GridCell{Column: column, ColumnSpan: span, RowSpan: 1})
+}
+
+// rows returns the rectangle spanning span rows starting from the given row,
+// when the area is split into n rows.
+func rows(area Rect, gap float64, n, row, span int) Rect {
+	return Cell(area, gap, 1, n, GridCell{Row: row, ColumnSpan: 1, RowSpan: span})
+}
+
+// GridCell is a cell in a grid, possibly spanning multiple columns and rows.
+//
+// The column and row are 0-based.
+type GridCell struct {
+	Column     int
+	Row        int
+	ColumnSpan int
+	RowSpan    int
+}
+
+// ParseGrid parses the grid size given as <cols>x<rows>, e.g. "3x2".
+func ParseGrid(spec string) (int, int, error) {
+	colsSpec, rowsSpec, ok := strings.Cut(spec, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid grid '%v', expected <cols>x<rows>", spec)
+	}
+	cols, err := strconv.Atoi(colsSpec)
+	if err != nil || cols < 1 {
+		return 0, 0, fmt.Errorf("invalid number of columns in grid '%v'", spec)
+	}
+	rows, err := strconv.Atoi(rowsSpec)
+	if err != nil || rows < 1 {
+		return 0, 0, fmt.Errorf("invalid number of rows in grid '%v'", spec)
+	}
+	return cols, rows, nil
+}
+
+// ParseCell parses the cell of a grid with the given size.
+//
+// The cell is either the 1-based index of the cell, counting row by row from the top-left cell,
+// or the 1-based <col>,<row>, optionally followed by the number of columns and rows to span,
+// e.g. "1,1,2,2" for the top-left cell spanning two columns and two rows.
+func ParseCell(spec string, cols, rows int) (GridCell, error) {
+	var values []int
+	for _, part := range strings.Split(spec, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || value < 1 {
+			return GridCell{}, fmt.Errorf("invalid cell '%v'", spec)
+		}
+		values = append(values, value)
+	}
+
+	cell := GridCell{ColumnSpan: 1, RowSpan: 1}
+	switch len(values) {
+	case 1:
+		if values[0] > cols*rows {
+			return GridCell{}, fmt.Errorf("cell %d is outside of the %dx%d grid", values[0], cols, rows)
+		}
+		cell.Column = (values[0] - 1) % cols
+		cell.Row = (values[0] - 1) / cols
+	case 4:
+		cell.ColumnSpan = values[2]
+		cell.RowSpan = values[3]
+		fallthrough
+	case 2:
+		cell.Column = values[0] - 1
+		cell.Row = values[1] - 1
+	default:
+		return GridCell{}, fmt.Errorf("invalid cell '%v', expected <index> or <col>,<row>[,<colspan>,<rowspan>]", spec)
+	}
+	if cell.Column+cell.ColumnSpan > cols || cell.Row+cell.RowSpan > rows {
+		return GridCell{}, fmt.Errorf("cell '%v' is outside of the %dx%d grid", spec, cols, rows)
+	}
+	return cell, nil
+}
+
+// Cell returns the rectangle of the cell, when the area is split into a grid of the given size.
+//
+// The gap is kept between the cells, and between the cells and the edges of the area.
+func Cell(area Rect, gap float64, cols, rows int, cell GridCell) Rect {
+	width := (area.Width - float64(cols+1)*gap) / float64(cols)
+	height := (area.Height - float64(rows+1)*gap) / float64(rows)
+	return Rect{
+		X:      area.X + gap + float64(cell.Column)*(width+gap),
+		Y:      area.Y + gap + float64(cell.Row)*(height+gap),
+		Width:  float64(cell.ColumnSpan)*width + float64(cell.ColumnSpan-1)*gap,
+		Height: float64(cell.RowSpan)*height + float64(cell.RowSpan-1)*gap,
+	}
+}
+
+// Arrange returns the new rectangles of the tiles arranged in the working area, in the same order as the tiles.
+//
+// The arrangements are:
+//   - cascade: the tiles keep their size, and are offset diagonally from the top-left corner so each title is visible.
+//   - tile: the tiles fill a grid of equal cells without overlapping, the last row's tiles are widened to fill the row.
+//   - stack: the tiles fill the width of the area in a single column of equal rows.
+func Arrange(arrangement string, area Rect, tiles []Rect, gap float64) ([]Rect, error) {
+	n := len(tiles)
+	arranged := make([]Rect, 0, n)
+	switch arrangement {
+	case "cascade":
+		for i, tile := range tiles {
+			width := min(tile.Width, area.Width-2*gap)
+			height := min(tile.Height, area.Height-2*gap)
+			// Start over from the top-left corner when the next tile wouldn't fit anymore.
+			steps := 1
+			if room := min(area.Width-2*gap-width, area.Height-2*gap-height); room > 0 {
+				steps = int(room/CascadeStep) + 1
+			}
+			offset := float64(i%steps) * CascadeStep
+			arranged = append(arranged, Rect{X: area.X + gap + offset, Y: area.Y + gap + offset, Width: width, Height: height})
+		}
+	case "tile":
+		cols := int(math.Ceil(math.Sqrt(float64(n))))
+		rows := 0
+		if cols > 0 {
+			rows = (n + cols - 1) / cols
+		}
+		for i := range tiles {
+			cell := GridCell{Column: i % cols, Row: i / cols, ColumnSpan: 1, RowSpan: 1}
+			if cell.Row == rows-1 {
+				// Split the last row between the remaining tiles.
+				remaining := n - cell.Row*cols
+				arranged = append(arranged, Cell(area, gap, remaining, rows, cell))
+				continue
+			}
+			arranged = append(arranged, Cell(area, gap, cols, rows, cell))
+		}
+	case "stack":
+		for i := range tiles {
+			arranged = append(arranged, Cell(area, gap, 1, n, GridCell{Row: i, ColumnSpan: 1, RowSpan: 1}))
+		}
+	default:
+		return nil, fmt.Errorf("invalid arrangement '%v'", arrangement)
+	}
+	return arranged, nil
+}
+
+// Resize returns the new rectangle of the tile resized according to the spec.
+//
+// The spec is <width>[x<height>], where both are either "+N" or "-N" to grow or shrink by N pixels,
+// "N%" for a fraction of the working area, or "N" for a fixed size in pixels. Without the height,
+// the width spec is used for both. The tile stays centered on its current center, but is kept
+// inside the working area.
+func Resize(spec string, area, tile Rect, gap float64) (Rect, error) {
+	widthSpec, heightSpec, ok := strings.Cut(spec, "x")
+	if !ok {
+		heightSpec = widthSpec
+	}
+	width, err := resizeDimension(widthSpec, tile.Width, area.Width)
+	if err != nil {
+		return Rect{}, fmt.Errorf("invalid size '%v': %w", spec, err)
+	}
+	height, err := resizeDimension(heightSpec, tile.Height, area.Height)
+	if err != nil {
+		return Rect{}, fmt.Errorf("invalid size '%v': %w", spec, err)
+	}
+	width = min(max(width, 1), area.Width-2*gap)
+	height = min(max(height, 1), area.Height-2*gap)
+
+	resized := Rect{
+		X:      tile.X + (tile.Width-width)/2,
+		Y:      tile.Y + (tile.Height-height)/2,
+		Width:  width,
+		Height: height,
+	}
+	resized.X = min(max(resized.X, area.X+gap), area.X+area.Width-width-gap)
+	resized.Y = min(max(resized.Y, area.Y+gap), area.Y+area.Height-height-gap)
+	return resized, nil
+}
+
+// resizeDimension returns the new size of one dimension of the tile.
+func resizeDimension(spec string, current, available float64) (float64, error) {
+	switch {
+	case strings.HasSuffix(spec, "%"):
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(spec, "%"), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return 0, fmt.Errorf("invalid percentage '%v'", spec)
+		}
+		return available * percent / 100, nil
+	case strings.HasPrefix(spec, "+"), strings.HasPrefix(spec, "-"):
+		step, err := strconv.ParseFloat(spec, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid step '%v'", spec)
+		}
+		return current + step, nil
+	}
+	size, err := strconv.ParseFloat(spec, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid size '%v'", spec)
+	}
+	return size, nil
+}
@@ -0,0 +1,271 @@
+package placement
+
+import (
+	"testing"
+
+	"github.com/soderluk/nirimgr/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTile(t *testing.T) {
+	tile, err := Tile(models.WindowLayout{
+		TilePosInWorkspaceView: []float64{100, 50},
+		TileSize:               []float64{808, 608},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, Rect{X: 100, Y: 50, Width: 808, Height: 608}, tile)
+
+	_, err = Tile(models.WindowLayout{TileSize: []float64{808, 608}})
+	assert.Error(t, err)
+}
+
+func TestWindowSize(t *testing.T) {
+	width, height := WindowSize(models.WindowLayout{WindowOffsetInTile: []float64{4, 4}}, 808, 608)
+	assert.Equal(t, int32(800), width)
+	assert.Equal(t, int32(600), height)
+
+	width, height = WindowSize(models.WindowLayout{}, 808, 608)
+	assert.Equal(t, int32(808), width)
+	assert.Equal(t, int32(608), height)
+}
+
+func TestExclusiveZones(t *testing.T) {
+	layers := []*models.LayerSurface{
+		{Namespace: "waybar", Output: "DP-1"},
+		{Namespace: "waybar", Output: "DP-1"},
+		{Namespace: "waybar", Output: "HDMI-A-1"},
+		{Namespace: "notifications", Output: "DP-1"},
+		{Namespace: "dock", Output: "DP-1"},
+	}
+	zones := map[string]models.Insets{
+		"waybar": {Top: 34},
+		"dock":   {Bottom: 60},
+	}
+	assert.Equal(t, []models.Insets{{Top: 34}, {Bottom: 60}}, ExclusiveZones(layers, "DP-1", zones))
+	assert.Equal(t, []models.Insets{{Top: 34}}, ExclusiveZones(layers, "HDMI-A-1", zones))
+	assert.Empty(t, ExclusiveZones(layers, "eDP-1", zones))
+}
+
+func TestWorkingArea(t *testing.T) {
+	output := models.LogicalOutput{Width: 1920, Height: 1080}
+	assert.Equal(t, Rect{Width: 1920, Height: 1080}, WorkingArea(output, nil))
+	assert.Equal(t,
+		Rect{X: 10, Y: 34, Width: 1910, Height: 986},
+		WorkingArea(output, []models.Insets{{Top: 34}, {Bottom: 60, Left: 10}}),
+	)
+}
+
+func TestPlace(t *testing.T) {
+	area := Rect{Y: 30, Width: 1920, Height: 1050}
+	tile := Rect{X: 500, Y: 500, Width: 800, Height: 600}
+
+	tests := []struct {
+		position string
+		want     Rect
+	}{
+		{"top-left", Rect{10, 40, 800, 600}},
+		{"top", Rect{560, 40, 800, 600}},
+		{"top-right", Rect{1110, 40, 800, 600}},
+		{"left", Rect{10, 255, 800, 600}},
+		{"center", Rect{560, 255, 800, 600}},
+		{"right", Rect{1110, 255, 800, 600}},
+		{"bottom-left", Rect{10, 470, 800, 600}},
+		{"bottom", Rect{560, 470, 800, 600}},
+		{"bottom-right", Rect{1110, 470, 800, 600}},
+		{"left-half", Rect{10, 40, 945, 1030}},
+		{"right-half", Rect{965, 40, 945, 1030}},
+		{"top-half", Rect{10, 40, 1900, 510}},
+		{"bottom-half", Rect{10, 560, 1900, 510}},
+		{"left-third", Rect{10, 40, 626.6666666666666, 1030}},
+		{"center-third", Rect{646.6666666666666, 40, 626.6666666666666, 1030}},
+		{"right-third", Rect{1283.3333333333333, 40, 626.6666666666666, 1030}},
+		{"left-two-thirds", Rect{10, 40, 1263.3333333333333, 1030}},
+		{"right-two-thirds", Rect{646.6666666666666, 40, 1263.3333333333333, 1030}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.position, func(t *testing.T) {
+			got, err := Place(tt.position, area, tile, 10)
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.want.X, got.X, 0.001)
+			assert.InDelta(t, tt.want.Y, got.Y, 0.001)
+			assert.InDelta(t, tt.want.Width, got.Width, 0.001)
+			assert.InDelta(t, tt.want.Height, got.Height, 0.001)
+		})
+	}
+	assert.Len(t, Positions, len(tests), "all positions should be tested")
+
+	_, err := Place("middle", area, tile, 10)
+	assert.Error(t, err)
+}
+
+func TestMove(t *testing.T) {
+	area := Rect{Y: 30, Width: 1920, Height: 1050}
+	tile := Rect{X: 500, Y: 500, Width: 800, Height: 600}
+
+	got, err := Move("left", area, tile, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, Rect{1, 500, 800, 600}, got)
+	got, _ = Move("right", area, tile, 1)
+	assert.Equal(t, Rect{1119, 500, 800, 600}, got)
+	got, _ = Move("up", area, tile, 1)
+	assert.Equal(t, Rect{500, 31, 800, 600}, got)
+	got, _ = Move("down", area, tile, 1)
+	assert.Equal(t, Rect{500, 479, 800, 600}, got)
+
+	_, err = Move("sideways", area, tile, 1)
+	assert.Error(t, err)
+}
+
+func TestParseGrid(t *testing.T) {
+	cols, rows, err := ParseGrid("3x2")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, cols)
+	assert.Equal(t, 2, rows)
+
+	for _, spec := range []string{"3", "0x2", "3xa", "x"} {
+		_, _, err := ParseGrid(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestParseCell(t *testing.T) {
+	tests := []struct {
+		spec string
+		want GridCell
+	}{
+		{"1", GridCell{Column: 0, Row: 0, ColumnSpan: 1, RowSpan: 1}},
+		{"5", GridCell{Column: 1, Row: 1, ColumnSpan: 1, RowSpan: 1}},
+		{"3,2", GridCell{Column: 2, Row: 1, ColumnSpan: 1, RowSpan: 1}},
+		{"1,1,2,2", GridCell{Column: 0, Row: 0, ColumnSpan: 2, RowSpan: 2}},
+	}
+	for _, tt := range tests {
+		got, err := ParseCell(tt.spec, 3, 2)
+		assert.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.want, got, tt.spec)
+	}
+
+	for _, spec := range []string{"7", "0", "4,1", "1,3", "2,1,3,1", "1,2,3", "a"} {
+		_, err := ParseCell(spec, 3, 2)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestCell(t *testing.T) {
+	area := Rect{Y: 30, Width: 1920, Height: 1050}
+	assert.Equal(t, Rect{X: 10, Y: 40, Width: 945, Height: 510}, Cell(area, 10, 2, 2, GridCell{ColumnSpan: 1, RowSpan: 1}))
+	assert.Equal(t,
+		Rect{X: 965, Y: 40, Width: 945, Height: 1030},
+		Cell(area, 10, 2, 2, GridCell{Column: 1, ColumnSpan: 1, RowSpan: 2}),
+	)
+}
+
+func TestResize(t *testing.T) {
+	area := Rect{Y: 30, Width: 1920, Height: 1050}
+	tile := Rect{X: 500, Y: 200, Width: 800, Height: 600}
+
+	tests := []struct {
+		spec string
+		want Rect
+	}{
+		// Grow around the center.
+		{"+100", Rect{X: 450, Y: 150, Width: 900, Height: 700}},
+		{"-100x+0", Rect{X: 550, Y: 200, Width: 700, Height: 600}},
+		{"50%", Rect{X: 420, Y: 237.5, Width: 960, Height: 525}},
+		{"1000x500", Rect{X: 400, Y: 250, Width: 1000, Height: 500}},
+		// Kept inside the working area.
+		{"+1000", Rect{X: 10, Y: 40, Width: 1800, Height: 1030}},
+	}
+	for _, tt := range tests {
+		got, err := Resize(tt.spec, area, tile, 10)
+		assert.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.want, got, tt.spec)
+	}
+
+	for _, spec := range []string{"", "abc", "0%", "150%", "+a", "-5x?"} {
+		_, err := Resize(spec, area, tile, 10)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestAdjacentOutput(t *testing.T) {
+	// A laptop below the middle of two side-by-side monitors, and a disabled output.
+	outputs := []*models.Output{
+		{Name: "DP-1", Logical: models.LogicalOutput{X: 0, Y: 0, Width: 1920, Height: 1080}},
+		{Name: "DP-2", Logical: models.LogicalOutput{X: 1920, Y: 0, Width: 1080, Height: 1920}},
+		{Name: "eDP-1", Logical: models.LogicalOutput{X: 960, Y: 1080, Width: 1280, Height: 800}},
+		{Name: "HDMI-A-1"},
+	}
+	tests := []struct {
+		current   int
+		direction string
+		want      string
+	}{
+		{0, "right", "DP-2"},
+		{1, "left", "DP-1"},
+		{0, "down", "eDP-1"},
+		{2, "up", "DP-1"},
+	}
+	for _, tt := range tests {
+		got, err := AdjacentOutput(outputs, outputs[tt.current], tt.direction)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.want, got.Name, "%v of %v", tt.direction, outputs[tt.current].Name)
+		}
+	}
+
+	_, err := AdjacentOutput(outputs, outputs[0], "left")
+	assert.Error(t, err)
+	// The laptop overlaps the right monitor horizontally, so the monitor isn't to its right.
+	_, err = AdjacentOutput(outputs, outputs[2], "right")
+	assert.Error(t, err)
+	_, err = AdjacentOutput(outputs, outputs[0], "sideways")
+	assert.Error(t, err)
+}
+
+func TestTransfer(t *testing.T) {
+	from := Rect{Y: 30, Width: 1920, Height: 1050}
+	to := Rect{Width: 3840, Height: 2100}
+	tile := Rect{X: 480, Y: 292.5, Width: 960, Height: 525}
+	assert.Equal(t, Rect{X: 960, Y: 525, Width: 1920, Height: 1050}, Transfer(tile, from, to))
+}
+
+func TestSnap(t *testing.T) {
+	assert.Equal(t, Rect{X: 100.8, Y: 50.4, Width: 10, Height: 10}, Snap(Rect{X: 100.7, Y: 50.5, Width: 10, Height: 10}, 1.25))
+	assert.Equal(t, Rect{X: 101, Y: 50}, Snap(Rect{X: 100.7, Y: 50.2}, 0))
+}
+
+func TestArrange(t *testing.T) {
+	area := Rect{Y: 30, Width: 1920, Height: 1050}
+	tiles := []Rect{{Width: 800, Height: 600}, {Width: 400, Height: 300}, {Width: 2000, Height: 300}}
+
+	got, err := Arrange("cascade", area, tiles, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Rect{
+		{X: 10, Y: 40, Width: 800, Height: 600},
+		{X: 42, Y: 72, Width: 400, Height: 300},
+		// The wide tile is shrunk to the area, so it starts over from the corner.
+		{X: 10, Y: 40, Width: 1900, Height: 300},
+	}, got)
+
+	got, err = Arrange("tile", area, tiles, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Rect{
+		{X: 10, Y: 40, Width: 945, Height: 510},
+		{X: 965, Y: 40, Width: 945, Height: 510},
+		// The last row is filled by the remaining tile.
+		{X: 10, Y: 560, Width: 1900, Height: 510},
+	}, got)
+
+	got, err = Arrange("stack", area, tiles[:2], 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Rect{
+		{X: 10, Y: 40, Width: 1900, Height: 510},
+		{X: 10, Y: 560, Width: 1900, Height: 510},
+	}, got)
+
+	got, err = Arrange("tile", area, nil, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	_, err = Arrange("spiral", area, tiles, 10)
+	assert.Error(t, err)
+}
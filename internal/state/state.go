@@ -0,0 +1,383 @@
+// Package state persists nirimgr's state between command invocations.
+//
+// The commands run on a key-bind and exit right away, so anything we need to remember between
+// invocations (e.g. which windows were moved to the scratchpad) is stored in a small JSON file
+// at $XDG_STATE_HOME/nirimgr/state.json, defaulting to ~/.local/state/nirimgr/state.json.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/soderluk/nirimgr/models"
+)
+
+// DryRun keeps Save from writing the state, so a dry-run doesn't change what nirimgr remembers.
+var DryRun bool
+
+// userHomeDir is the function used to retrieve the user's home directory.
+// It can be overridden in tests.
+var userHomeDir = os.UserHomeDir
+
+// ScratchpadWindow is a window moved to the scratchpad by nirimgr.
+type ScratchpadWindow struct {
+	// ID is the ID of the window.
+	ID uint64 `json:"id"`
+	// WasFloating tells if the window was floating before it was moved to the scratchpad.
+	//
+	// Only then the size and position below are recorded.
+	WasFloating bool `json:"wasFloating"`
+	// Width is the original width of the window.
+	Width int32 `json:"width,omitempty"`
+	// Height is the original height of the window.
+	Height int32 `json:"height,omitempty"`
+	// X is the original x position of the tile in the workspace view.
+	X float64 `json:"x,omitempty"`
+	// Y is the original y position of the tile in the workspace view.
+	Y float64 `json:"y,omitempty"`
+}
+
+// NewScratchpadWindow returns the scratchpad window for the given window, recording its current floating size and position.
+func NewScratchpadWindow(window *models.Window) ScratchpadWindow {
+	s := ScratchpadWindow{ID: window.ID, WasFloating: window.IsFloating}
+	if !window.IsFloating {
+		return s
+	}
+	if len(window.Layout.WindowSize) == 2 {
+		s.Width = window.Layout.WindowSize[0]
+		s.Height = window.Layout.WindowSize[1]
+	}
+	if len(window.Layout.TilePosInWorkspaceView) == 2 {
+		s.X = window.Layout.TilePosInWorkspaceView[0]
+		s.Y = window.Layout.TilePosInWorkspaceView[1]
+	}
+	return s
+}
+
+// State contains the persisted state.
+type State struct {
+	// Scratchpad contains the windows moved to the scratchpad by nirimgr.
+	//
+	// The windows are in most recently used order, i.e. the most recently shown window first.
+	Scratchpad []ScratchpadWindow `json:"scratchpad"`
+	// FocusCycles contains the ongoing spawn-or-focus cycles, keyed by the spawn-or-focus key.
+	FocusCycles map[string]FocusCycle `json:"focusCycles,omitempty"`
+	// BroughtWindows contains the windows brought to the focused workspace by spawn-or-focus.
+	BroughtWindows []BroughtWindow `json:"broughtWindows,omitempty"`
+}
+
+// BroughtWindow is a window brought to the focused workspace, remembering where it came from.
+type BroughtWindow struct {
+	// ID is the ID of the window.
+	ID uint64 `json:"id"`
+	// WorkspaceID is the ID of the workspace the window came from.
+	WorkspaceID uint64 `json:"workspaceId"`
+	// Output is the name of the output of the workspace the window came from.
+	Output string `json:"output,omitempty"`
+	// WasFloating tells if the window was floating before it was brought.
+	WasFloating bool `json:"wasFloating"`
+}
+
+// FocusCycle is an ongoing spawn-or-focus cycle through the matching windows.
+//
+// The order of the windows is fixed when the cycle starts, since focusing the windows
+// would change the most recently focused order while cycling.
+type FocusCycle struct {
+	// Windows contains the IDs of the windows in the cycle, in the order they're focused.
+	Windows []uint64 `json:"windows"`
+	// Previous is the ID of the window that was focused before the cycle started, if any.
+	Previous uint64 `json:"previous,omitempty"`
+}
+
+// Update updates the cycle with the currently matching windows.
+//
+// The closed windows are removed from the cycle, and the new windows are appended in the given order.
+func (c FocusCycle) Update(windows []uint64) FocusCycle {
+	updated := FocusCycle{Previous: c.Previous}
+	for _, id := range c.Windows {
+		if slices.Contains(windows, id) {
+			updated.Windows = append(updated.Windows, id)
+		}
+	}
+	for _, id := range windows {
+		if !slices.Contains(updated.Windows, id) {
+			updated.Windows = append(updated.Windows, id)
+		}
+	}
+	return updated
+}
+
+// Next returns the ID of the window after the focused window in the cycle.
+//
+// Returns false if the focused window is the last one in the cycle, i.e. the cycle is complete.
+// If the focused window is not in the cycle, the first window is returned.
+func (c FocusCycle) Next(focused uint64) (uint64, bool) {
+	idx := slices.Index(c.Windows, focused)
+	if idx == len(c.Windows)-1 {
+		return 0, false
+	}
+	return c.Windows[idx+1], true
+}
+
+// Path returns the path to the state file.
+func Path() (string, error) {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		homeDir, err := userHomeDir()
+		if err != nil {
+			return "", err
+		}
+		stateHome = filepath.Join(homeDir, ".local", "state")
+	}
+	return filepath.Join(stateHome, "nirimgr", "state.json"), nil
+}
+
+// Load reads the state from the state file.
+//
+// If the state file doesn't exist yet, an empty state is returned.
+func Load() (*State, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path) // #nosec G304
+	if errors.Is(err, fs.ErrNotExist) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s *State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s == nil {
+		s = &State{}
+	}
+	return s, nil
+}
+
+// Save writes the state to the state file.
+//
+// The file is written to a temporary file first, and then renamed, so a concurrent Load
+// never sees a partially written file.
+func (s *State) Save() error {
+	if DryRun {
+		return nil
+	}
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "state-*.json")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Update loads the state, applies the update to it, and saves it.
+//
+// An exclusive lock on the state file is held throughout, so the concurrent updates from the events daemon
+// and the commands don't overwrite each other's changes. The update must not call Update, since the lock is held.
+func Update(update func(*State)) error {
+	if DryRun {
+		s, err := Load()
+		if err != nil {
+			return err
+		}
+		update(s)
+		return nil
+	}
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	unlock, err := lock(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	s, err := Load()
+	if err != nil {
+		return err
+	}
+	update(s)
+	return s.Save()
+}
+
+// ScratchpadWindow returns the tracked scratchpad window with the given ID.
+func (s *State) ScratchpadWindow(id uint64) (ScratchpadWindow, bool) {
+	for _, w := range s.Scratchpad {
+		if w.ID == id {
+			return w, true
+		}
+	}
+	return ScratchpadWindow{}, false
+}
+
+// AddScratchpadWindow starts tracking the given scratchpad window as the most recently used one.
+//
+// If the window is already tracked, the original size and position are kept.
+func (s *State) AddScratchpadWindow(window ScratchpadWindow) {
+	if _, ok := s.ScratchpadWindow(window.ID); ok {
+		return
+	}
+	s.Scratchpad = append([]ScratchpadWindow{window}, s.Scratchpad...)
+}
+
+// TouchScratchpadWindow marks the scratchpad window with the given ID as the most recently used one.
+func (s *State) TouchScratchpadWindow(id uint64) {
+	for idx, w := range s.Scratchpad {
+		if w.ID == id {
+			copy(s.Scratchpad[1:idx+1], s.Scratchpad[:idx])
+			s.Scratchpad[0] = w
+			return
+		}
+	}
+}
+
+// SortScratchpadWindows returns the windows in most recently used order.
+//
+// The tracked windows come first, followed by the untracked windows in their original order.
+func (s *State) SortScratchpadWindows(windows []*models.Window) []*models.Window {
+	byID := make(map[uint64]*models.Window, len(windows))
+	for _, w := range windows {
+		byID[w.ID] = w
+	}
+	sorted := make([]*models.Window, 0, len(windows))
+	for _, tracked := range s.Scratchpad {
+		if w, ok := byID[tracked.ID]; ok {
+			sorted = append(sorted, w)
+			delete(byID, tracked.ID)
+		}
+	}
+	for _, w := range windows {
+		if _, ok := byID[w.ID]; ok {
+			sorted = append(sorted, w)
+		}
+	}
+	return sorted
+}
+
+// NextScratchpadWindow returns the window following the current window in most recently used order.
+//
+// The candidates are the hidden windows to choose from. The order wraps around, so after the least
+// recently used window we continue from the most recently used one. Cycling doesn't change the order,
+// so repeated calls go through all the candidates.
+func (s *State) NextScratchpadWindow(current uint64, candidates []*models.Window) *models.Window {
+	if len(candidates) == 0 {
+		return nil
+	}
+	sorted := s.SortScratchpadWindows(candidates)
+	position := -1
+	for idx, tracked := range s.Scratchpad {
+		if tracked.ID == current {
+			position = idx
+			break
+		}
+	}
+	if position == -1 {
+		return sorted[0]
+	}
+	// The tracked candidates after the current window come next, then the untracked candidates,
+	// and finally the tracked candidates before the current window.
+	after := make(map[uint64]struct{})
+	for _, tracked := range s.Scratchpad[position+1:] {
+		after[tracked.ID] = struct{}{}
+	}
+	for _, w := range sorted {
+		if _, ok := after[w.ID]; ok {
+			return w
+		}
+	}
+	for _, w := range sorted {
+		if _, ok := s.ScratchpadWindow(w.ID); !ok {
+			return w
+		}
+	}
+	return sorted[0]
+}
+
+// SetFocusCycle remembers the spawn-or-focus cycle with the given key.
+func (s *State) SetFocusCycle(key string, cycle FocusCycle) {
+	if s.FocusCycles == nil {
+		s.FocusCycles = make(map[string]FocusCycle)
+	}
+	s.FocusCycles[key] = cycle
+}
+
+// BroughtWindow returns the brought window with the given ID.
+func (s *State) BroughtWindow(id uint64) (BroughtWindow, bool) {
+	for _, w := range s.BroughtWindows {
+		if w.ID == id {
+			return w, true
+		}
+	}
+	return BroughtWindow{}, false
+}
+
+// AddBroughtWindow remembers the brought window, replacing the earlier origin if the window was already brought.
+func (s *State) AddBroughtWindow(window BroughtWindow) {
+	s.RemoveBroughtWindow(window.ID)
+	s.BroughtWindows = append(s.BroughtWindows, window)
+}
+
+// RemoveBroughtWindow forgets the brought window with the given ID.
+func (s *State) RemoveBroughtWindow(id uint64) {
+	s.BroughtWindows = slices.DeleteFunc(s.BroughtWindows, func(w BroughtWindow) bool {
+		return w.ID == id
+	})
+}
+
+// RemoveScratchpadWindow stops tracking the scratchpad window with the given ID.
+func (s *State) RemoveScratchpadWindow(id uint64) {
+	scratchpad := s.Scratchpad[:0]
+	for _, w := range s.Scratchpad {
+		if w.ID != id {
+			scratchpad = append(scratchpad, w)
+		}
+	}
+	s.Scratchpad = scratchpad
+}
+
+// Prune stops tracking the scratchpad and brought windows that are no longer open.
+func (s *State) Prune(windows []*models.Window) {
+	open := make(map[uint64]struct{}, len(windows))
+	for _, w := range windows {
+		open[w.ID] = struct{}{}
+	}
+	scratchpad := s.Scratchpad[:0]
+	for _, w := range s.Scratchpad {
+		if _, ok := open[w.ID]; ok {
+			scratchpad = append(scratchpad, w)
+		}
+	}
+	s.Scratchpad = scratchpad
+	s.BroughtWindows = slices.DeleteFunc(s.BroughtWindows, func(w BroughtWindow) bool {
+		_, ok := open[w.ID]
+		return !ok
+	})
+}
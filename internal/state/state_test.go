@@ -0,0 +1,240 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/soderluk/nirimgr/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPath(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/tmp/state")
+	path, err := Path()
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/state/nirimgr/state.json", path)
+
+	t.Setenv("XDG_STATE_HOME", "")
+	oldUserHomeDir := userHomeDir
+	userHomeDir = func() (string, error) { return "/home/user", nil }
+	defer func() { userHomeDir = oldUserHomeDir }()
+	path, err = Path()
+	assert.NoError(t, err)
+	assert.Equal(t, "/home/user/.local/state/nirimgr/state.json", path)
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	s, err := Load()
+	assert.NoError(t, err)
+	assert.Empty(t, s.Scratchpad)
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", dir)
+
+	s := &State{}
+	s.AddScratchpadWindow(ScratchpadWindow{ID: 1, WasFloating: true, Width: 400, Height: 300, X: 10, Y: 20})
+	assert.NoError(t, s.Save())
+
+	loaded, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, s, loaded)
+
+	entries, err := os.ReadDir(filepath.Join(dir, "nirimgr"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file should be removed")
+}
+
+func TestSaveDryRun(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	DryRun = true
+	defer func() { DryRun = false }()
+
+	s := &State{}
+	s.AddScratchpadWindow(ScratchpadWindow{ID: 1})
+	assert.NoError(t, s.Save())
+
+	loaded, err := Load()
+	assert.NoError(t, err)
+	assert.Empty(t, loaded.Scratchpad)
+}
+
+func TestUpdate(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	// The concurrent updates don't lose each other's changes.
+	var wg sync.WaitGroup
+	for id := uint64(1); id <= 20; id++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, Update(func(s *State) {
+				s.AddScratchpadWindow(ScratchpadWindow{ID: id})
+			}))
+		}()
+	}
+	wg.Wait()
+
+	loaded, err := Load()
+	assert.NoError(t, err)
+	assert.Len(t, loaded.Scratchpad, 20)
+
+	DryRun = true
+	defer func() { DryRun = false }()
+	assert.NoError(t, Update(func(s *State) { s.RemoveScratchpadWindow(1) }))
+	loaded, err = Load()
+	assert.NoError(t, err)
+	assert.Len(t, loaded.Scratchpad, 20)
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "nirimgr"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "nirimgr", "state.json"), []byte("{"), 0o600))
+	_, err := Load()
+	assert.Error(t, err)
+}
+
+func TestNewScratchpadWindow(t *testing.T) {
+	window := &models.Window{
+		ID:         5,
+		IsFloating: true,
+		Layout: models.WindowLayout{
+			WindowSize:             []int32{800, 600},
+			TilePosInWorkspaceView: []float64{100, 50},
+		},
+	}
+	assert.Equal(t, ScratchpadWindow{ID: 5, WasFloating: true, Width: 800, Height: 600, X: 100, Y: 50}, NewScratchpadWindow(window))
+
+	window.IsFloating = false
+	assert.Equal(t, ScratchpadWindow{ID: 5}, NewScratchpadWindow(window))
+}
+
+func TestScratchpadWindows(t *testing.T) {
+	s := &State{}
+	s.AddScratchpadWindow(ScratchpadWindow{ID: 1, Width: 100})
+	s.AddScratchpadWindow(ScratchpadWindow{ID: 2})
+	s.AddScratchpadWindow(ScratchpadWindow{ID: 3})
+	// Adding an already tracked window keeps the original geometry.
+	s.AddScratchpadWindow(ScratchpadWindow{ID: 1, Width: 200})
+
+	w, ok := s.ScratchpadWindow(1)
+	assert.True(t, ok)
+	assert.Equal(t, int32(100), w.Width)
+	assert.Len(t, s.Scratchpad, 3)
+
+	s.RemoveScratchpadWindow(2)
+	_, ok = s.ScratchpadWindow(2)
+	assert.False(t, ok)
+
+	s.Prune([]*models.Window{{ID: 3}, {ID: 4}})
+	assert.Equal(t, []ScratchpadWindow{{ID: 3}}, s.Scratchpad)
+}
+
+func TestTouchScratchpadWindow(t *testing.T) {
+	s := &State{}
+	s.AddScratchpadWindow(ScratchpadWindow{ID: 1})
+	s.AddScratchpadWindow(ScratchpadWindow{ID: 2})
+	s.AddScratchpadWindow(ScratchpadWindow{ID: 3})
+	assert.Equal(t, []ScratchpadWindow{{ID: 3}, {ID: 2}, {ID: 1}}, s.Scratchpad)
+
+	s.TouchScratchpadWindow(1)
+	assert.Equal(t, []ScratchpadWindow{{ID: 1}, {ID: 3}, {ID: 2}}, s.Scratchpad)
+	s.TouchScratchpadWindow(1)
+	assert.Equal(t, []ScratchpadWindow{{ID: 1}, {ID: 3}, {ID: 2}}, s.Scratchpad)
+	s.TouchScratchpadWindow(42)
+	assert.Equal(t, []ScratchpadWindow{{ID: 1}, {ID: 3}, {ID: 2}}, s.Scratchpad)
+}
+
+func TestSortScratchpadWindows(t *testing.T) {
+	s := &State{Scratchpad: []ScratchpadWindow{{ID: 3}, {ID: 1}, {ID: 9}}}
+	windows := []*models.Window{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+
+	var ids []uint64
+	for _, w := range s.SortScratchpadWindows(windows) {
+		ids = append(ids, w.ID)
+	}
+	assert.Equal(t, []uint64{3, 1, 2, 4}, ids)
+}
+
+func TestNextScratchpadWindow(t *testing.T) {
+	s := &State{Scratchpad: []ScratchpadWindow{{ID: 1}, {ID: 2}, {ID: 3}}}
+	all := []*models.Window{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+	hidden := func(visible uint64) []*models.Window {
+		var windows []*models.Window
+		for _, w := range all {
+			if w.ID != visible {
+				windows = append(windows, w)
+			}
+		}
+		return windows
+	}
+
+	// Cycling goes through all the windows without the order changing.
+	current := uint64(1)
+	var order []uint64
+	for range 5 {
+		next := s.NextScratchpadWindow(current, hidden(current))
+		order = append(order, next.ID)
+		current = next.ID
+	}
+	assert.Equal(t, []uint64{2, 3, 4, 1, 2}, order)
+
+	// Without a current window, the most recently used one is next.
+	assert.Equal(t, uint64(1), s.NextScratchpadWindow(0, all).ID)
+	assert.Nil(t, s.NextScratchpadWindow(1, nil))
+}
+
+func TestFocusCycle(t *testing.T) {
+	cycle := FocusCycle{Windows: []uint64{3, 1, 2}, Previous: 7}
+
+	// Closed windows are dropped, new windows are appended.
+	cycle = cycle.Update([]uint64{4, 2, 3})
+	assert.Equal(t, FocusCycle{Windows: []uint64{3, 2, 4}, Previous: 7}, cycle)
+
+	next, ok := cycle.Next(3)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), next)
+	next, ok = cycle.Next(2)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(4), next)
+	_, ok = cycle.Next(4)
+	assert.False(t, ok, "the cycle is complete on the last window")
+
+	// A window outside the cycle starts from the first window.
+	next, ok = cycle.Next(9)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), next)
+
+	_, ok = FocusCycle{}.Next(1)
+	assert.False(t, ok)
+
+	s := &State{}
+	s.SetFocusCycle("firefox", cycle)
+	assert.Equal(t, map[string]FocusCycle{"firefox": cycle}, s.FocusCycles)
+}
+
+func TestBroughtWindows(t *testing.T) {
+	s := &State{}
+	s.AddBroughtWindow(BroughtWindow{ID: 1, WorkspaceID: 3})
+	s.AddBroughtWindow(BroughtWindow{ID: 2, WorkspaceID: 4, WasFloating: true})
+	// Bringing the window again replaces the origin.
+	s.AddBroughtWindow(BroughtWindow{ID: 1, WorkspaceID: 5})
+
+	w, ok := s.BroughtWindow(1)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(5), w.WorkspaceID)
+	assert.Len(t, s.BroughtWindows, 2)
+
+	s.Prune([]*models.Window{{ID: 1}})
+	_, ok = s.BroughtWindow(2)
+	assert.False(t, ok)
+
+	s.RemoveBroughtWindow(1)
+	assert.Empty(t, s.BroughtWindows)
+}
@@ -4,6 +4,11 @@
 // but can be used in models.
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // ModeToSet is the output mode to set.
 type ModeToSet struct {
 	// Automatic tells that niri will pick the mode automatically.
@@ -83,6 +88,29 @@ type Layer struct {
 	Overlay string `json:"Overlay,omitempty"`
 }
 
+// UnmarshalJSON unmarshals the layer from niri's variant name, e.g. "Top".
+func (l *Layer) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		// Use an alias type, so we don't recurse into this method.
+		type layer Layer
+		return json.Unmarshal(data, (*layer)(l))
+	}
+	switch name {
+	case "Background":
+		l.Background = name
+	case "Bottom":
+		l.Bottom = name
+	case "Top":
+		l.Top = name
+	case "Overlay":
+		l.Overlay = name
+	default:
+		return fmt.Errorf("unknown layer '%v'", name)
+	}
+	return nil
+}
+
 // LayerSurfaceKeyboardInteractivity is the keyboard interactivity modes for a layer-shell surface.
 type LayerSurfaceKeyboardInteractivity struct {
 	// None tells that the surface cannot receive keyboard focus.
@@ -93,6 +121,27 @@ type LayerSurfaceKeyboardInteractivity struct {
 	OnDemand string `json:"OnDemand,omitempty"`
 }
 
+// UnmarshalJSON unmarshals the keyboard interactivity from niri's variant name, e.g. "OnDemand".
+func (k *LayerSurfaceKeyboardInteractivity) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		// Use an alias type, so we don't recurse into this method.
+		type keyboardInteractivity LayerSurfaceKeyboardInteractivity
+		return json.Unmarshal(data, (*keyboardInteractivity)(k))
+	}
+	switch name {
+	case "None":
+		k.None = name
+	case "Exclusive":
+		k.Exclusive = name
+	case "OnDemand":
+		k.OnDemand = name
+	default:
+		return fmt.Errorf("unknown keyboard interactivity '%v'", name)
+	}
+	return nil
+}
+
 // Transform is the output transformation, which goes counter-clockwise.
 type Transform struct {
 	// Normal is untransformed.
@@ -4,11 +4,15 @@
 package models
 
 import (
+	"bytes"
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"regexp"
+	"slices"
+	"time"
 )
 
 // NiriRequest is the representation of a simple niri request.
@@ -74,17 +78,46 @@ type ActionConfig struct {
 	// "when": "model.ID == 3" for a WorkspaceActivated event, evaluates to true if
 	// the workspace that was activated has an ID: 3.
 	When string `json:"when,omitempty"`
+	// Target selects the window and workspace the action targets, see the Target* constants.
+	//
+	// The IDs of the target are set on the action fields that aren't given in the params. Without a target,
+	// the zero-valued ID fields are filled from the matched window, workspace or event.
+	Target string `json:"target,omitempty"`
 	// Params contains the rest of the parameters to be defined for the action.
 	//
 	// NOTE: You don't need to set "params" in your config, but just the fields, i.e. "ID": 6 instead
 	// of "Params": {"ID": 6}.
 	Params json.RawMessage `json:"-"`
+	// Steps contains the ordered steps of the "steps" action, see StepsAction.
+	Steps []Step `json:"-"`
 }
 
+// The targets of an ActionConfig.
+//
+// Besides these, the target can be a selector "window:<condition>" or "workspace:<condition>", which targets
+// the first window or workspace (by ID) for which the condition evaluates to true, e.g. "window:model.AppID == 'firefox'".
+const (
+	// TargetSelf targets the matched window or workspace, or the window and workspace of the event.
+	TargetSelf = "self"
+	// TargetFocused targets the focused window and workspace.
+	TargetFocused = "focused"
+	// TargetActiveWindow targets the active window of the matched workspace, or of the matched window's workspace.
+	TargetActiveWindow = "activeWindow"
+	// TargetWorkspace targets the workspace of the matched window, or the matched workspace, without a window.
+	TargetWorkspace = "workspace"
+	// TargetNone doesn't set any IDs, so the action is performed with the params as given.
+	TargetNone = "none"
+)
+
 // UnmarshalJSON overrides the unmarshaling of an ActionConfig.
 //
 // We don't want to have the "When" field present in the rawMessage.
 func (a *ActionConfig) UnmarshalJSON(data []byte) error {
+	// The "steps" action is a list of steps instead of the action params.
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return json.Unmarshal(trimmed, &a.Steps)
+	}
+
 	// Unmarshal the JSON into a map
 	var rawMap map[string]json.RawMessage
 	if err := json.Unmarshal(data, &rawMap); err != nil {
@@ -104,6 +137,14 @@ func (a *ActionConfig) UnmarshalJSON(data []byte) error {
 		delete(rawMap, "when")
 	}
 
+	// Extract the "target" field the same way.
+	if targetJSON, ok := rawMap["target"]; ok {
+		if err := json.Unmarshal(targetJSON, &a.Target); err != nil {
+			return err
+		}
+		delete(rawMap, "target")
+	}
+
 	// Marshal the map without the when field back to JSON
 	cleanJSON, err := json.Marshal(rawMap)
 	if err != nil {
@@ -115,6 +156,254 @@ func (a *ActionConfig) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// The keys for limiting the handler runs per event, see EventHandler.Per.
+const (
+	// PerEvent limits the handler runs of all the events with the same name together.
+	PerEvent = "event"
+	// PerID limits the handler runs separately for each window or workspace the event refers to.
+	PerID = "id"
+)
+
+// EventHandler contains the actions to perform on an event, on which windows or workspaces, and how often to perform them.
+//
+// The actions are the keys other than the reserved "debounce", "throttle", "per", "match" and "exclude", e.g.
+//
+//	"WindowLayoutsChanged": {
+//		"debounce": "250ms",
+//		"per": "id",
+//		"match": [{ "appId": "firefox" }],
+//		"Exec": { "command": ["notify-send", "Layout settled"] }
+//	}
+type EventHandler struct {
+	// Debounce waits until no events have arrived for the duration, and then handles the latest event.
+	Debounce string `json:"debounce,omitempty"`
+	// Throttle handles the first event right away, and then at most one event per duration, the latest one.
+	Throttle string `json:"throttle,omitempty"`
+	// Per is PerEvent (default) or PerID, telling which events are debounced or throttled together.
+	Per string `json:"per,omitempty"`
+	// Match list of matches for the window or workspace the event refers to. The event is handled if any of them matches.
+	Match []Match `json:"match,omitempty"`
+	// Exclude list of matches for the window or workspace the event refers to, to be excluded from the match.
+	Exclude []Match `json:"exclude,omitempty"`
+	// Actions contains the actions to perform on the event.
+	Actions map[string]ActionConfig `json:"-"`
+}
+
+// UnmarshalJSON unmarshals the event handler, putting the non-reserved keys into the actions.
+func (h *EventHandler) UnmarshalJSON(data []byte) error {
+	var rawMap map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rawMap); err != nil {
+		return err
+	}
+	reserved := map[string]*string{
+		"debounce": &h.Debounce,
+		"throttle": &h.Throttle,
+		"per":      &h.Per,
+	}
+	matches := map[string]*[]Match{
+		"match":   &h.Match,
+		"exclude": &h.Exclude,
+	}
+	for key, raw := range rawMap {
+		if target, ok := reserved[key]; ok {
+			if err := json.Unmarshal(raw, target); err != nil {
+				return fmt.Errorf("invalid event handler %v: %w", key, err)
+			}
+			continue
+		}
+		if target, ok := matches[key]; ok {
+			if err := json.Unmarshal(raw, target); err != nil {
+				return fmt.Errorf("invalid event handler %v: %w", key, err)
+			}
+			continue
+		}
+		var actionConfig ActionConfig
+		if err := json.Unmarshal(raw, &actionConfig); err != nil {
+			return fmt.Errorf("invalid event handler action %v: %w", key, err)
+		}
+		if h.Actions == nil {
+			h.Actions = make(map[string]ActionConfig)
+		}
+		h.Actions[key] = actionConfig
+	}
+	if h.Debounce != "" && h.Throttle != "" {
+		return errors.New("event handler can't have both debounce and throttle")
+	}
+	if h.Per != "" && h.Per != PerEvent && h.Per != PerID {
+		return fmt.Errorf("invalid event handler per '%v'", h.Per)
+	}
+	_, _, err := h.Limit()
+	return err
+}
+
+// Filtered tells whether the handler only handles the events of the matching windows or workspaces.
+func (h EventHandler) Filtered() bool {
+	return len(h.Match) > 0 || len(h.Exclude) > 0
+}
+
+// Matches checks if the window or workspace the event refers to matches the handler's matches and excludes.
+//
+// Either one can be nil, e.g. the window for workspace events. The matches work like the rule matches,
+// with the window matches (title, appId) checked against the window, and the workspace matches (name, output)
+// against the workspace.
+func (h EventHandler) Matches(window *Window, workspace *Workspace) bool {
+	if len(h.Match) > 0 {
+		matched := false
+		for _, m := range h.Match {
+			if m.Matches(window, workspace) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, m := range h.Exclude {
+		if m.Matches(window, workspace) {
+			return false
+		}
+	}
+	return true
+}
+
+// Limit returns the debounce or throttle duration of the handler, telling which one it is.
+//
+// The duration is 0 if the handler runs on every event.
+func (h EventHandler) Limit() (time.Duration, bool, error) {
+	spec, debounce := h.Debounce, true
+	if spec == "" {
+		spec, debounce = h.Throttle, false
+	}
+	if spec == "" {
+		return 0, false, nil
+	}
+	duration, err := time.ParseDuration(spec)
+	if err != nil || duration <= 0 {
+		return 0, false, fmt.Errorf("invalid event handler duration '%v'", spec)
+	}
+	return duration, debounce, nil
+}
+
+// StepsAction is the name of the action that performs ordered steps instead of a single action.
+//
+// Its value is a list of steps, e.g.
+//
+//	"actions": {
+//		"steps": [
+//			{ "MoveWindowToFloating": {} },
+//			{ "delay": "200ms", "SetWindowHeight": { "change": { "SetFixed": 600 } } },
+//			{ "FloatingPlace": { "position": "center" }, "retry": { "until": "model.IsFloating" } },
+//			{ "when": "model.Title == 'Bitwarden'", "then": [{ "FloatingPlace": { "position": "top-right" } }], "else": [{ "CenterWindow": {} }] }
+//		]
+//	}
+const StepsAction = "steps"
+
+// Step is a step in the ordered steps of an action config.
+//
+// The step waits for the delay, then performs its actions and the Then steps if the "when" condition
+// evaluates to true, otherwise the Else steps. The actions are the keys other than the reserved
+// "delay", "when", "then", "else" and "retry", like the actions of a rule. The actions within a step
+// are not ordered, so use a step for each action when the order matters.
+type Step struct {
+	// Delay is the duration to wait before the step, e.g. "200ms".
+	Delay string `json:"delay,omitempty"`
+	// When is the condition for performing the actions and the Then steps, evaluated on the current model.
+	When string `json:"when,omitempty"`
+	// Then contains the steps to perform after the actions, if the condition evaluates to true.
+	Then []Step `json:"then,omitempty"`
+	// Else contains the steps to perform if the condition evaluates to false.
+	Else []Step `json:"else,omitempty"`
+	// Retry repeats the actions until its condition holds.
+	Retry *Retry `json:"retry,omitempty"`
+	// Actions contains the actions of the step.
+	Actions map[string]ActionConfig `json:"-"`
+}
+
+// UnmarshalJSON unmarshals the step, putting the non-reserved keys into the actions.
+func (s *Step) UnmarshalJSON(data []byte) error {
+	var rawMap map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rawMap); err != nil {
+		return err
+	}
+	reserved := map[string]any{
+		"delay": &s.Delay,
+		"when":  &s.When,
+		"then":  &s.Then,
+		"else":  &s.Else,
+		"retry": &s.Retry,
+	}
+	for key, raw := range rawMap {
+		if target, ok := reserved[key]; ok {
+			if err := json.Unmarshal(raw, target); err != nil {
+				return fmt.Errorf("invalid step %v: %w", key, err)
+			}
+			continue
+		}
+		var actionConfig ActionConfig
+		if err := json.Unmarshal(raw, &actionConfig); err != nil {
+			return fmt.Errorf("invalid step action %v: %w", key, err)
+		}
+		if s.Actions == nil {
+			s.Actions = make(map[string]ActionConfig)
+		}
+		s.Actions[key] = actionConfig
+	}
+	if _, err := s.DelayDuration(); err != nil {
+		return err
+	}
+	if s.Retry != nil {
+		if s.Retry.Until == "" {
+			return errors.New("retry needs an until condition")
+		}
+		if _, err := time.ParseDuration(cmp.Or(s.Retry.Interval, "0s")); err != nil {
+			return fmt.Errorf("invalid retry interval '%v'", s.Retry.Interval)
+		}
+	}
+	return nil
+}
+
+// DelayDuration returns the delay of the step, or 0 if not configured.
+func (s Step) DelayDuration() (time.Duration, error) {
+	if s.Delay == "" {
+		return 0, nil
+	}
+	delay, err := time.ParseDuration(s.Delay)
+	if err != nil {
+		return 0, fmt.Errorf("invalid delay '%v'", s.Delay)
+	}
+	return delay, nil
+}
+
+// DefaultRetryAttempts is the number of attempts of a retried step, if not configured.
+const DefaultRetryAttempts = 10
+
+// DefaultRetryInterval is the interval between the attempts of a retried step, if not configured.
+const DefaultRetryInterval = 100 * time.Millisecond
+
+// Retry repeats the actions of a step until the condition evaluates to true.
+type Retry struct {
+	// Until is the condition, evaluated on the refreshed model after each attempt.
+	Until string `json:"until"`
+	// Attempts is the maximum number of attempts. Defaults to DefaultRetryAttempts.
+	Attempts int `json:"attempts,omitempty"`
+	// Interval is the duration to wait after each attempt, e.g. "100ms". Defaults to DefaultRetryInterval.
+	Interval string `json:"interval,omitempty"`
+}
+
+// Limits returns the maximum number of attempts, and the interval between them, using the defaults if not configured.
+func (r Retry) Limits() (int, time.Duration) {
+	attempts := r.Attempts
+	if attempts <= 0 {
+		attempts = DefaultRetryAttempts
+	}
+	interval, err := time.ParseDuration(r.Interval)
+	if err != nil || interval <= 0 {
+		interval = DefaultRetryInterval
+	}
+	return attempts, interval
+}
+
 // Config contains the configuration for nirimgr.
 type Config struct {
 	// LogLevel is the log level to use. One of "DEBUG", "INFO", "WARN", "ERROR" should be used. Defaults to "INFO".
@@ -125,10 +414,15 @@ type Config struct {
 	//
 	// NOTE: The named workspace must be defined in niri config.
 	ScratchpadWorkspace string `json:"scratchpadWorkspace,omitempty"`
-	// Launcher is the full path to the preferred launcher to use.
+	// Launcher is the preferred launcher to use, either a preset name (fuzzel, rofi, wofi, tofi, bemenu, dmenu)
+	// or the full path to the launcher binary. Defaults to fuzzel.
 	Launcher string `json:"launcher,omitempty"`
-	// LauncherOptions are the options to pass to the launcher.
+	// LauncherOptions are the options to pass to the launcher, separated by whitespace.
+	//
+	// Deprecated: Use LauncherArgs, which doesn't need to split the options.
 	LauncherOptions string `json:"launcherOptions"`
+	// LauncherArgs are the arguments to pass to the launcher. Overrides the preset's default arguments.
+	LauncherArgs []string `json:"launcherArgs,omitempty"`
 	// SpawnOrFocus defines the configuration for the spawn-or-focus command.
 	SpawnOrFocus SpawnOrFocus `json:"spawnOrFocus"`
 	// ShowScratchpadActions lists actions that should be performed on the shown scratchpad window.
@@ -136,8 +430,16 @@ type Config struct {
 	// The `scratch show` command will always run MoveWindowToWorkspace and FocusWindow, but in addition can perform the following actions,
 	// e.g. if you want to center the window or resize it or something.
 	ShowScratchpadActions map[string]json.RawMessage `json:"showScratchpadActions,omitempty"`
+	// ScratchpadShow configures where the scratchpad windows are shown.
+	ScratchpadShow ScratchpadShow `json:"scratchpadShow"`
+	// Scratchpads contains the named scratchpads, keyed by the scratchpad name.
+	//
+	// A named scratchpad is toggled with `nirimgr scratch toggle <name>`.
+	Scratchpads map[string]Scratchpad `json:"scratchpads,omitempty"`
+	// Placement configures the placement of floating windows with the floating commands.
+	Placement Placement `json:"placement"`
 	// Events contains the event types to listen to, and the actions to run on the specified event.
-	Events map[string]map[string]ActionConfig `json:"events,omitempty"`
+	Events map[string]EventHandler `json:"events,omitempty"`
 }
 
 // GetRules returns the configured rules.
@@ -151,20 +453,204 @@ func (c *Config) GetRules() []Rule {
 
 // SpawnOrFocus defines the rules and commands to run for the spawn-or-focus command.
 type SpawnOrFocus struct {
+	// Rules restrict the windows matching the commands without their own match.
+	//
+	// Deprecated: Each command matches its own app id exactly. Use the command's match to target other windows.
 	Rules []Rule `json:"rules,omitempty"`
-	// Command is the command to spawn for the spawnOrFocus command.
-	Commands map[string][]string `json:"commands,omitempty"`
+	// Commands contains the commands to spawn for the spawnOrFocus command, keyed by the app id.
+	Commands map[string]SpawnOrFocusCommand `json:"commands,omitempty"`
+	// Order is the order to cycle through multiple matching windows, either "mru" (most recently focused first)
+	// or "id" (oldest window first). Defaults to "mru".
+	Order string `json:"order,omitempty"`
+	// Select opens the launcher to select the window when multiple windows match, instead of cycling through them.
+	Select bool `json:"select,omitempty"`
 }
 
 // Command returns the specified command to run for the given key.
 func (s *SpawnOrFocus) Command(key string) ([]string, error) {
+	command, err := s.Lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	return command.Command, nil
+}
+
+// Lookup returns the spawn-or-focus command configuration for the given key.
+func (s *SpawnOrFocus) Lookup(key string) (SpawnOrFocusCommand, error) {
 	command, ok := s.Commands[key]
 	if !ok {
-		return nil, fmt.Errorf("could not read command for %s", key)
+		return SpawnOrFocusCommand{}, fmt.Errorf("could not read command for %s", key)
+	}
+	switch command.Mode {
+	case "", SpawnOrFocusModeFocus, SpawnOrFocusModeBring, SpawnOrFocusModeBringFloating, SpawnOrFocusModeScratchpad:
+	default:
+		return SpawnOrFocusCommand{}, fmt.Errorf("invalid mode '%v' for %s", command.Mode, key)
+	}
+	if _, err := command.WaitTimeout(); err != nil {
+		return SpawnOrFocusCommand{}, fmt.Errorf("%w for %s", err, key)
 	}
 	return command, nil
 }
 
+// The modes of a spawn-or-focus command, i.e. what to do with an already running window.
+const (
+	// SpawnOrFocusModeFocus focuses the window wherever it is.
+	SpawnOrFocusModeFocus = "focus"
+	// SpawnOrFocusModeBring moves the window to the focused workspace.
+	SpawnOrFocusModeBring = "bring"
+	// SpawnOrFocusModeBringFloating moves the window to the focused workspace as a floating window.
+	SpawnOrFocusModeBringFloating = "bring-floating"
+	// SpawnOrFocusModeScratchpad toggles the window between the scratchpad workspace and the focused workspace.
+	SpawnOrFocusModeScratchpad = "scratchpad"
+)
+
+// SpawnOrFocusCommand defines the command to spawn, and what to do with the window if it's already running.
+//
+// In the configuration, the command can also be given as just the command array, e.g. `"Slack": ["/usr/bin/slack"]`.
+type SpawnOrFocusCommand struct {
+	// Command is the command to spawn if no window matches.
+	Command []string `json:"command"`
+	// Match list of matches to target the window. Defaults to the app id being exactly the command key.
+	Match []Match `json:"match,omitempty"`
+	// Exclude list of matches to be excluded from the match.
+	Exclude []Match `json:"exclude,omitempty"`
+	// When is an expression the matching window must also satisfy, e.g. `model.IsFloating`.
+	When string `json:"when,omitempty"`
+	// Dir is the working directory for the spawned command.
+	Dir string `json:"dir,omitempty"`
+	// Env contains additional environment variables for the spawned command.
+	Env map[string]string `json:"env,omitempty"`
+	// Mode is one of "focus", "bring", "bring-floating" or "scratchpad". Defaults to "focus".
+	//
+	// With the bring modes, the window is sent back to the workspace it came from on the next invocation.
+	Mode string `json:"mode,omitempty"`
+	// Actions lists actions to perform on the window when it's brought to the focused workspace,
+	// e.g. to set the size or position.
+	Actions map[string]json.RawMessage `json:"actions,omitempty"`
+	// SpawnActions lists actions to perform on the window after it's spawned.
+	//
+	// If set, spawn-or-focus waits for the spawned window to appear before exiting.
+	SpawnActions map[string]json.RawMessage `json:"spawnActions,omitempty"`
+	// Timeout is how long to wait for the spawned window, e.g. "10s". Defaults to 5 seconds.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Rule returns the window rule used to find the window for the command with the given key.
+//
+// Without any configured matches, the window's app id must be exactly the key.
+func (c SpawnOrFocusCommand) Rule(key string) Rule {
+	match := c.Match
+	if len(match) == 0 {
+		match = []Match{{AppID: "^" + regexp.QuoteMeta(key) + "$"}}
+	}
+	return Rule{Type: "window", Match: match, Exclude: c.Exclude}
+}
+
+// DefaultSpawnTimeout is the default time to wait for a spawned window.
+const DefaultSpawnTimeout = 5 * time.Second
+
+// WaitTimeout returns how long to wait for the spawned window.
+func (c SpawnOrFocusCommand) WaitTimeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return DefaultSpawnTimeout, nil
+	}
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout '%v': %w", c.Timeout, err)
+	}
+	return timeout, nil
+}
+
+// UnmarshalJSON unmarshals the command either from the full object, or from the command array shorthand.
+func (c *SpawnOrFocusCommand) UnmarshalJSON(data []byte) error {
+	var command []string
+	if err := json.Unmarshal(data, &command); err == nil {
+		*c = SpawnOrFocusCommand{Command: command}
+		return nil
+	}
+	// Use an alias type, so we don't recurse into this method.
+	type spawnOrFocusCommand SpawnOrFocusCommand
+	var full spawnOrFocusCommand
+	if err := json.Unmarshal(data, &full); err != nil {
+		return err
+	}
+	*c = SpawnOrFocusCommand(full)
+	return nil
+}
+
+// ScratchpadShow configures where the scratchpad windows are shown.
+type ScratchpadShow struct {
+	// OnFocusedOutput shows the window on the active workspace of the focused output, instead of the focused workspace.
+	OnFocusedOutput bool `json:"onFocusedOutput,omitempty"`
+	// Center centers the shown window on the output, using the output's logical size.
+	Center bool `json:"center,omitempty"`
+}
+
+// Placement configures the placement of floating windows.
+type Placement struct {
+	// Gaps is the gap in logical pixels kept between the placed windows and the edges of the working area.
+	Gaps float64 `json:"gaps,omitempty"`
+	// ExclusiveZones contains the space reserved by layer-shell surfaces (e.g. bars), keyed by the layer namespace.
+	//
+	// niri doesn't report the size of the layer-shell surfaces, so the zones must be configured. A zone is
+	// only applied to the outputs where the Layers request shows a surface with that namespace.
+	ExclusiveZones map[string]Insets `json:"exclusiveZones,omitempty"`
+	// Layouts contains the named grid layouts for the `floating grid` command.
+	Layouts map[string]GridLayout `json:"layouts,omitempty"`
+}
+
+// GridLayout is a named grid layout for floating windows.
+type GridLayout struct {
+	// Grid is the size of the grid as <cols>x<rows>, e.g. "3x2".
+	Grid string `json:"grid"`
+	// Cells contains named cells of the grid, e.g. "main": "1,1,2,2".
+	Cells map[string]string `json:"cells,omitempty"`
+}
+
+// Insets are the sizes of the edges of an area in logical pixels.
+type Insets struct {
+	Top    float64 `json:"top,omitempty"`
+	Right  float64 `json:"right,omitempty"`
+	Bottom float64 `json:"bottom,omitempty"`
+	Left   float64 `json:"left,omitempty"`
+}
+
+// Scratchpad defines a named scratchpad.
+//
+// The window matching the rule is toggled between the scratchpad workspace and the focused workspace.
+// If no window matches, the command is spawned.
+type Scratchpad struct {
+	// Match list of matches to target the scratchpad window.
+	Match []Match `json:"match,omitempty"`
+	// Exclude list of matches to be excluded from the match.
+	Exclude []Match `json:"exclude,omitempty"`
+	// Command is the command to spawn if no window matches.
+	//
+	// If omitted, the spawnOrFocus command with the same name as the scratchpad is used.
+	Command []string `json:"command,omitempty"`
+	// Actions lists actions to perform on the window when it's shown, e.g. to set the size or position.
+	//
+	// These are performed after the showScratchpadActions.
+	Actions map[string]json.RawMessage `json:"actions,omitempty"`
+}
+
+// Rule returns the window rule used to find the scratchpad window.
+func (s Scratchpad) Rule() Rule {
+	return Rule{Type: "window", Match: s.Match, Exclude: s.Exclude}
+}
+
+// Scratchpad returns the named scratchpad with the given name.
+func (c *Config) Scratchpad(name string) (Scratchpad, error) {
+	scratchpad, ok := c.Scratchpads[name]
+	if !ok {
+		return Scratchpad{}, fmt.Errorf("no scratchpad named %s", name)
+	}
+	if len(scratchpad.Match) == 0 {
+		return Scratchpad{}, fmt.Errorf("scratchpad %s has no match rules", name)
+	}
+	return scratchpad, nil
+}
+
 // Match is used to match a window.
 type Match struct {
 	// Title matches the title of the window. Used only for rules with type "window".
@@ -179,58 +665,96 @@ type Match struct {
 
 // WindowMatches checks if the window matches the specified rule match.
 func (m Match) WindowMatches(window Window) bool {
-	if m.Title == "" && m.AppID == "" {
-		slog.Debug("Title and AppID empty for window", "window", window.ID)
+	return m.ExplainWindow(window).Matched
+}
+
+// Matches checks if the window and the workspace match the specified match.
+//
+// The window fields of the match are checked against the window, and the workspace fields against the workspace,
+// so e.g. a match with both an appId and a name requires the window and the workspace to match.
+func (m Match) Matches(window *Window, workspace *Workspace) bool {
+	hasWindowFields := m.Title != "" || m.AppID != ""
+	hasWorkspaceFields := m.Name != "" || m.Output != ""
+	if !hasWindowFields && !hasWorkspaceFields {
 		return false
 	}
-	matched := true
-
-	if m.Title != "" {
-		titleMatch, err := regexp.MatchString(m.Title, window.Title)
-		if err != nil {
-			slog.Error("Could not match title", "error", err.Error())
-			return false
-		}
-		matched = matched && titleMatch
+	if hasWindowFields && (window == nil || !m.WindowMatches(*window)) {
+		return false
 	}
-	if m.AppID != "" {
-		appMatch, err := regexp.MatchString(m.AppID, window.AppID)
-		if err != nil {
-			slog.Error("Could not match AppID", "error", err.Error())
-			return false
-		}
-		matched = matched && appMatch
+	if hasWorkspaceFields && (workspace == nil || !m.WorkspaceMatches(*workspace)) {
+		return false
 	}
-
-	return matched
+	return true
 }
 
 // WorkspaceMatches checks if the workspace matches the specified rule match.
 func (m Match) WorkspaceMatches(workspace Workspace) bool {
+	return m.ExplainWorkspace(workspace).Matched
+}
+
+// FieldMatch tells how a field of a match was matched, see Match.ExplainWindow.
+type FieldMatch struct {
+	// Field is the name of the field in the match, e.g. "appId".
+	Field string
+	// Pattern is the regular expression of the field.
+	Pattern string
+	// Value is the value of the window or workspace the pattern was matched against.
+	Value string
+	// Matched tells if the pattern matched the value.
+	Matched bool
+	// Error is the error in the pattern, if it's not a valid regular expression.
+	Error string
+}
+
+// MatchResult tells how a match was matched, field by field.
+type MatchResult struct {
+	// Fields contains the results of the fields given in the match.
+	Fields []FieldMatch
+	// Matched tells if all the fields matched. A match without any fields for the type never matches.
+	Matched bool
+}
+
+// ExplainWindow matches the window against the title and app-id of the match, telling how each of them matched.
+func (m Match) ExplainWindow(window Window) MatchResult {
+	if m.Title == "" && m.AppID == "" {
+		slog.Debug("Title and AppID empty for window", "window", window.ID)
+		return MatchResult{}
+	}
+	return explainFields([]FieldMatch{
+		{Field: "title", Pattern: m.Title, Value: window.Title},
+		{Field: "appId", Pattern: m.AppID, Value: window.AppID},
+	})
+}
+
+// ExplainWorkspace matches the workspace against the name and output of the match, telling how each of them matched.
+func (m Match) ExplainWorkspace(workspace Workspace) MatchResult {
 	if m.Name == "" && m.Output == "" {
 		slog.Debug("Name and Output empty for workspace", "workspace", workspace.ID)
-		return false
+		return MatchResult{}
 	}
-	matched := true
+	return explainFields([]FieldMatch{
+		{Field: "name", Pattern: m.Name, Value: workspace.Name},
+		{Field: "output", Pattern: m.Output, Value: workspace.Output},
+	})
+}
 
-	if m.Name != "" {
-		titleMatch, err := regexp.MatchString(m.Name, workspace.Name)
-		if err != nil {
-			slog.Error("Could not match Name", "error", err.Error())
-			return false
+// explainFields matches the patterns of the given fields, skipping the fields without a pattern.
+func explainFields(fields []FieldMatch) MatchResult {
+	result := MatchResult{Matched: true}
+	for _, field := range fields {
+		if field.Pattern == "" {
+			continue
 		}
-		matched = matched && titleMatch
-	}
-	if m.Output != "" {
-		appMatch, err := regexp.MatchString(m.Output, workspace.Output)
+		matched, err := regexp.MatchString(field.Pattern, field.Value)
 		if err != nil {
-			slog.Error("Could not match Output", "error", err.Error())
-			return false
+			slog.Error("Could not match "+field.Field, "error", err.Error())
+			field.Error = err.Error()
 		}
-		matched = matched && appMatch
+		field.Matched = matched
+		result.Matched = result.Matched && matched
+		result.Fields = append(result.Fields, field)
 	}
-
-	return matched
+	return result
 }
 
 // Rule contains the matches, excludes and actions for a window.
@@ -250,54 +774,59 @@ type Rule struct {
 
 // WindowMatches checks if the window matches the given rule.
 func (r Rule) WindowMatches(window Window) bool {
-	if r.Type != "window" && r.Type != "" {
-		return false
-	}
-
-	if len(r.Match) > 0 {
-		matched := false
-		for _, m := range r.Match {
-			if m.WindowMatches(window) {
-				matched = true
-				break
-			}
-		}
-		if !matched {
-			return false
-		}
-	}
-	for _, m := range r.Exclude {
-		if m.WindowMatches(window) {
-			return false
-		}
-	}
-	return true
+	return r.ExplainWindow(window).Matched
 }
 
 // WorkspaceMatches checks if the workspace matches the given rule.
 func (r *Rule) WorkspaceMatches(workspace Workspace) bool {
+	return r.ExplainWorkspace(workspace).Matched
+}
+
+// RuleResult tells how a rule was matched, match by match.
+type RuleResult struct {
+	// TypeMatches tells if the rule is for the type, i.e. a window or workspace rule. Nothing else is matched if not.
+	TypeMatches bool
+	// Matches contains the results of the rule's matches. Any of them needs to match, if there are any.
+	Matches []MatchResult
+	// Excludes contains the results of the rule's excludes. None of them may match.
+	Excludes []MatchResult
+	// Matched tells if the rule matched.
+	Matched bool
+}
+
+// ExplainWindow matches the window against the rule, telling how each of the matches and excludes matched.
+func (r Rule) ExplainWindow(window Window) RuleResult {
+	if r.Type != "window" && r.Type != "" {
+		return RuleResult{}
+	}
+	return explainRule(r, func(m Match) MatchResult { return m.ExplainWindow(window) })
+}
+
+// ExplainWorkspace matches the workspace against the rule, telling how each of the matches and excludes matched.
+func (r *Rule) ExplainWorkspace(workspace Workspace) RuleResult {
 	if r.Type != "workspace" {
-		return false
+		return RuleResult{}
 	}
+	return explainRule(*r, func(m Match) MatchResult { return m.ExplainWorkspace(workspace) })
+}
 
-	if len(r.Match) > 0 {
-		matched := false
-		for _, m := range r.Match {
-			if m.WorkspaceMatches(workspace) {
-				matched = true
-				break
-			}
-		}
-		if !matched {
-			return false
-		}
+// explainRule matches all the matches and excludes of the rule with the explain function.
+func explainRule(r Rule, explain func(Match) MatchResult) RuleResult {
+	result := RuleResult{TypeMatches: true}
+	matched := len(r.Match) == 0
+	for _, m := range r.Match {
+		matchResult := explain(m)
+		matched = matched || matchResult.Matched
+		result.Matches = append(result.Matches, matchResult)
 	}
+	excluded := false
 	for _, m := range r.Exclude {
-		if m.WorkspaceMatches(workspace) {
-			return false
-		}
+		excludeResult := explain(m)
+		excluded = excluded || excludeResult.Matched
+		result.Excludes = append(result.Excludes, excludeResult)
 	}
-	return true
+	result.Matched = matched && !excluded
+	return result
 }
 
 // Response contains the response from the Niri Socket.
@@ -407,6 +936,22 @@ type PickedColor struct {
 	RGB float64 `json:"rgb"`
 }
 
+// Timestamp is a moment in time, relative to niri's monotonic clock.
+type Timestamp struct {
+	// Secs is the number of whole seconds.
+	Secs uint64 `json:"secs"`
+	// Nanos is the fractional part of the timestamp in nanoseconds.
+	Nanos uint32 `json:"nanos"`
+}
+
+// Before tells whether the timestamp is before the other timestamp.
+func (t Timestamp) Before(other Timestamp) bool {
+	if t.Secs != other.Secs {
+		return t.Secs < other.Secs
+	}
+	return t.Nanos < other.Nanos
+}
+
 // VrrToSet is the output variable refresh rate to set.
 type VrrToSet struct {
 	// Vrr tells whether to enable variable refresh rate or not.
@@ -448,10 +993,18 @@ type Window struct {
 	IsUrgent bool `json:"is_urgent"`
 	// Layout shows position- and size-related properties of the window.
 	Layout WindowLayout `json:"layout"`
+	// FocusTimestamp is the timestamp when the window was most recently focused.
+	//
+	// This is nil if the window was never focused, or if niri is too old to report it.
+	FocusTimestamp *Timestamp `json:"focus_timestamp,omitempty"`
 	// Matched tells if the window matches a rule defined by nirimgr rules.
 	//
 	// This is not a part of the Niri Window model.
 	Matched bool
+	// Sticky tells if the window was marked sticky by the Sticky action in nirimgr rules.
+	//
+	// A sticky floating window follows the active workspace of its output. This is not a part of the Niri Window model.
+	Sticky bool
 }
 
 // WindowLayout shows the position- and size-related properties of a Window.
@@ -555,6 +1108,38 @@ func (ws WindowSlice) First() (*Window, error) {
 	return ws.Windows[0], nil
 }
 
+// SortByID returns the windows sorted by their ID.
+func (ws WindowSlice) SortByID() WindowSlice {
+	windows := slices.Clone(ws.Windows)
+	slices.SortStableFunc(windows, func(a, b *Window) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return WindowSlice{Windows: windows}
+}
+
+// SortByFocus returns the windows in most recently focused order.
+//
+// The windows without a focus timestamp come last, sorted by their ID.
+func (ws WindowSlice) SortByFocus() WindowSlice {
+	windows := ws.SortByID().Windows
+	slices.SortStableFunc(windows, func(a, b *Window) int {
+		switch {
+		case a.FocusTimestamp == nil && b.FocusTimestamp == nil:
+			return 0
+		case a.FocusTimestamp == nil:
+			return 1
+		case b.FocusTimestamp == nil:
+			return -1
+		case b.FocusTimestamp.Before(*a.FocusTimestamp):
+			return -1
+		case a.FocusTimestamp.Before(*b.FocusTimestamp):
+			return 1
+		}
+		return 0
+	})
+	return WindowSlice{Windows: windows}
+}
+
 // WorkspaceSlice is a wrapper for workspaces.
 type WorkspaceSlice struct {
 	Workspaces []*Workspace
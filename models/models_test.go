@@ -1,7 +1,10 @@
 package models
 
 import (
+	"encoding/json"
+	"slices"
 	"testing"
+	"time"
 )
 
 var testConfig = &Config{
@@ -173,3 +176,343 @@ func TestWorkspaceRules(t *testing.T) {
 		}
 	}
 }
+
+func TestScratchpad(t *testing.T) {
+	config := &Config{
+		Scratchpads: map[string]Scratchpad{
+			"term": {
+				Match:   []Match{{AppID: "^special-term$"}},
+				Exclude: []Match{{Title: "ignore"}},
+			},
+			"empty": {},
+		},
+	}
+
+	scratchpad, err := config.Scratchpad("term")
+	if err != nil {
+		t.Fatalf("Scratchpad(term) failed: %v", err)
+	}
+	rule := scratchpad.Rule()
+	if !rule.WindowMatches(Window{AppID: "special-term"}) {
+		t.Errorf("scratchpad rule should match special-term")
+	}
+	if rule.WindowMatches(Window{AppID: "special-term", Title: "ignore me"}) {
+		t.Errorf("scratchpad rule should exclude the title")
+	}
+	if rule.WindowMatches(Window{AppID: "term"}) {
+		t.Errorf("scratchpad rule should not match term")
+	}
+
+	if _, err := config.Scratchpad("empty"); err == nil {
+		t.Errorf("Scratchpad(empty) should fail without match rules")
+	}
+	if _, err := config.Scratchpad("missing"); err == nil {
+		t.Errorf("Scratchpad(missing) should fail")
+	}
+}
+
+func TestWindowSliceSort(t *testing.T) {
+	windows := WindowSlice{Windows: []*Window{
+		{ID: 3, FocusTimestamp: &Timestamp{Secs: 10, Nanos: 5}},
+		{ID: 1},
+		{ID: 4, FocusTimestamp: &Timestamp{Secs: 10, Nanos: 7}},
+		{ID: 2, FocusTimestamp: &Timestamp{Secs: 9}},
+		{ID: 0},
+	}}
+	ids := func(ws WindowSlice) []uint64 {
+		var ids []uint64
+		for _, w := range ws.Windows {
+			ids = append(ids, w.ID)
+		}
+		return ids
+	}
+
+	if got := ids(windows.SortByID()); !slices.Equal(got, []uint64{0, 1, 2, 3, 4}) {
+		t.Errorf("SortByID() = %v", got)
+	}
+	if got := ids(windows.SortByFocus()); !slices.Equal(got, []uint64{4, 3, 2, 0, 1}) {
+		t.Errorf("SortByFocus() = %v", got)
+	}
+	// The original slice is not modified.
+	if got := ids(windows); !slices.Equal(got, []uint64{3, 1, 4, 2, 0}) {
+		t.Errorf("original windows = %v", got)
+	}
+}
+
+func TestSpawnOrFocusCommands(t *testing.T) {
+	var spawnOrFocus SpawnOrFocus
+	data := `{
+		"commands": {
+			"Slack": ["/usr/bin/slack"],
+			"deezer": {"command": ["flatpak", "run", "dev.aunetx.deezer"], "mode": "bring-floating", "actions": {"CenterWindow": {}}},
+			"broken": {"command": ["foo"], "mode": "teleport"},
+			"slow": {"command": ["foo"], "spawnActions": {"MaximizeColumn": {}}, "timeout": "10s"},
+			"invalid-timeout": {"command": ["foo"], "timeout": "soon"}
+		}
+	}`
+	if err := json.Unmarshal([]byte(data), &spawnOrFocus); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	command, err := spawnOrFocus.Command("Slack")
+	if err != nil || !slices.Equal(command, []string{"/usr/bin/slack"}) {
+		t.Errorf("Command(Slack) = %v, %v", command, err)
+	}
+	deezer, err := spawnOrFocus.Lookup("deezer")
+	if err != nil {
+		t.Fatalf("Lookup(deezer) failed: %v", err)
+	}
+	if deezer.Mode != SpawnOrFocusModeBringFloating || len(deezer.Command) != 3 || len(deezer.Actions) != 1 {
+		t.Errorf("Lookup(deezer) = %+v", deezer)
+	}
+	if _, err := spawnOrFocus.Lookup("broken"); err == nil {
+		t.Errorf("Lookup(broken) should fail with an invalid mode")
+	}
+	slow, err := spawnOrFocus.Lookup("slow")
+	if err != nil {
+		t.Fatalf("Lookup(slow) failed: %v", err)
+	}
+	if timeout, _ := slow.WaitTimeout(); timeout != 10*time.Second {
+		t.Errorf("WaitTimeout() = %v, want 10s", timeout)
+	}
+	if timeout, _ := deezer.WaitTimeout(); timeout != DefaultSpawnTimeout {
+		t.Errorf("WaitTimeout() = %v, want the default", timeout)
+	}
+	if _, err := spawnOrFocus.Lookup("invalid-timeout"); err == nil {
+		t.Errorf("Lookup(invalid-timeout) should fail")
+	}
+	if _, err := spawnOrFocus.Lookup("missing"); err == nil {
+		t.Errorf("Lookup(missing) should fail")
+	}
+}
+
+func TestSpawnOrFocusCommandRule(t *testing.T) {
+	rule := SpawnOrFocusCommand{}.Rule("term")
+	if !rule.WindowMatches(Window{AppID: "term"}) {
+		t.Errorf("default rule should match the exact app id")
+	}
+	if rule.WindowMatches(Window{AppID: "special-term"}) {
+		t.Errorf("default rule should not match a substring of the app id")
+	}
+	if !(SpawnOrFocusCommand{}).Rule("org.gnome.Nautilus").WindowMatches(Window{AppID: "org.gnome.Nautilus"}) {
+		t.Errorf("default rule should quote the key")
+	}
+	if (SpawnOrFocusCommand{}).Rule("org.gnome.Nautilus").WindowMatches(Window{AppID: "orgXgnomeXNautilus"}) {
+		t.Errorf("default rule should not treat the key as a regex")
+	}
+
+	rule = SpawnOrFocusCommand{
+		Match:   []Match{{AppID: "^Alacritty$", Title: "notes"}},
+		Exclude: []Match{{Title: "draft"}},
+	}.Rule("notes")
+	if !rule.WindowMatches(Window{AppID: "Alacritty", Title: "notes"}) {
+		t.Errorf("custom rule should match")
+	}
+	if rule.WindowMatches(Window{AppID: "notes"}) {
+		t.Errorf("custom rule should replace the default app id match")
+	}
+	if rule.WindowMatches(Window{AppID: "Alacritty", Title: "notes draft"}) {
+		t.Errorf("custom rule should exclude the title")
+	}
+}
+
+func TestLayerSurfaceUnmarshal(t *testing.T) {
+	var layers []*LayerSurface
+	data := `[{"namespace": "waybar", "output": "DP-1", "layer": "Top", "keyboard_interactivity": "None"}]`
+	if err := json.Unmarshal([]byte(data), &layers); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if layers[0].Namespace != "waybar" || layers[0].Layer.Top != "Top" || layers[0].KeyboardInteractivity.None != "None" {
+		t.Errorf("Unexpected layer surface %+v", layers[0])
+	}
+
+	if err := json.Unmarshal([]byte(`"Middle"`), &Layer{}); err == nil {
+		t.Errorf("Unmarshal should fail for an unknown layer")
+	}
+}
+
+func TestActionConfigUnmarshal(t *testing.T) {
+	var actionConfig ActionConfig
+	if err := json.Unmarshal([]byte(`{"when": "model.ID == 1", "target": "focused", "id": 5}`), &actionConfig); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if actionConfig.When != "model.ID == 1" {
+		t.Errorf("When = %q, want %q", actionConfig.When, "model.ID == 1")
+	}
+	if actionConfig.Target != TargetFocused {
+		t.Errorf("Target = %q, want %q", actionConfig.Target, TargetFocused)
+	}
+	if string(actionConfig.Params) != `{"id":5}` {
+		t.Errorf("Params = %s, want %s", actionConfig.Params, `{"id":5}`)
+	}
+}
+
+func TestStepUnmarshal(t *testing.T) {
+	var actionConfig ActionConfig
+	err := json.Unmarshal([]byte(`[
+		{"MoveWindowToFloating": {}},
+		{"delay": "200ms", "SetWindowHeight": {"when": "model.IsFloating", "change": {"SetFixed": 600}}},
+		{"when": "model.IsFloating", "then": [{"CenterWindow": {}}], "else": [{"FocusWindow": {}}], "retry": {"until": "model.IsFocused"}}
+	]`), &actionConfig)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	steps := actionConfig.Steps
+	if len(steps) != 3 {
+		t.Fatalf("len(Steps) = %d, want 3", len(steps))
+	}
+	if _, ok := steps[0].Actions["MoveWindowToFloating"]; !ok || len(steps[0].Actions) != 1 {
+		t.Errorf("Steps[0].Actions = %v, want MoveWindowToFloating", steps[0].Actions)
+	}
+	if delay, _ := steps[1].DelayDuration(); delay != 200*time.Millisecond {
+		t.Errorf("Steps[1].DelayDuration() = %v, want 200ms", delay)
+	}
+	if height := steps[1].Actions["SetWindowHeight"]; height.When != "model.IsFloating" || string(height.Params) != `{"change":{"SetFixed":600}}` {
+		t.Errorf("Steps[1].Actions[SetWindowHeight] = %+v", height)
+	}
+	if steps[2].When != "model.IsFloating" || len(steps[2].Then) != 1 || len(steps[2].Else) != 1 || len(steps[2].Actions) != 0 {
+		t.Errorf("Steps[2] = %+v", steps[2])
+	}
+	if attempts, interval := steps[2].Retry.Limits(); attempts != DefaultRetryAttempts || interval != DefaultRetryInterval {
+		t.Errorf("Retry.Limits() = %v, %v, want the defaults", attempts, interval)
+	}
+
+	for _, data := range []string{
+		`[{"delay": "soon"}]`,
+		`[{"retry": {"attempts": 3}}]`,
+		`[{"retry": {"until": "true", "interval": "often"}}]`,
+		`[{"then": {}}]`,
+	} {
+		if err := json.Unmarshal([]byte(data), &ActionConfig{}); err == nil {
+			t.Errorf("Unmarshal(%s) expected an error", data)
+		}
+	}
+}
+
+func TestEventHandlerUnmarshal(t *testing.T) {
+	var handler EventHandler
+	if err := json.Unmarshal([]byte(`{"throttle": "1s", "per": "id", "FocusWindow": {"when": "model.ID == 1"}}`), &handler); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if handler.Per != PerID {
+		t.Errorf("Per = %q, want %q", handler.Per, PerID)
+	}
+	if action, ok := handler.Actions["FocusWindow"]; !ok || len(handler.Actions) != 1 || action.When != "model.ID == 1" {
+		t.Errorf("Actions = %+v, want FocusWindow", handler.Actions)
+	}
+	if duration, debounce, err := handler.Limit(); duration != time.Second || debounce || err != nil {
+		t.Errorf("Limit() = %v, %v, %v, want 1s, false, nil", duration, debounce, err)
+	}
+
+	for _, data := range []string{
+		`{"debounce": "1s", "throttle": "1s"}`,
+		`{"debounce": "soon"}`,
+		`{"throttle": "-1s"}`,
+		`{"per": "window"}`,
+	} {
+		if err := json.Unmarshal([]byte(data), &EventHandler{}); err == nil {
+			t.Errorf("Unmarshal(%s) expected an error", data)
+		}
+	}
+}
+
+func TestEventHandlerMatches(t *testing.T) {
+	var handler EventHandler
+	err := json.Unmarshal([]byte(`{
+		"match": [{"appId": "firefox"}, {"name": "^work$"}],
+		"exclude": [{"appId": "firefox", "output": "HDMI-A-1"}],
+		"FocusWindow": {}
+	}`), &handler)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !handler.Filtered() || len(handler.Actions) != 1 {
+		t.Fatalf("handler = %+v, want filtered with one action", handler)
+	}
+
+	firefox := &Window{ID: 1, AppID: "firefox"}
+	foot := &Window{ID: 2, AppID: "foot"}
+	work := &Workspace{ID: 1, Name: "work", Output: "eDP-1"}
+	other := &Workspace{ID: 2, Name: "other", Output: "HDMI-A-1"}
+	tests := []struct {
+		name      string
+		window    *Window
+		workspace *Workspace
+		want      bool
+	}{
+		{"matching window", firefox, work, true},
+		{"matching workspace", foot, work, true},
+		{"workspace event", nil, work, true},
+		{"no match", foot, other, false},
+		{"excluded", firefox, other, false},
+		{"nothing", nil, nil, false},
+	}
+	for _, tt := range tests {
+		if got := handler.Matches(tt.window, tt.workspace); got != tt.want {
+			t.Errorf("%s: Matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !(EventHandler{}).Matches(nil, nil) {
+		t.Errorf("Matches() without matches = false, want true")
+	}
+}
+
+func TestExplainWindow(t *testing.T) {
+	rule := Rule{
+		Match:   []Match{{AppID: "^zen$"}, {Title: "Bitwarden", AppID: "firefox"}},
+		Exclude: []Match{{Title: "("}, {Title: "Private"}},
+	}
+	window := Window{ID: 1, Title: "Bitwarden - Private", AppID: "firefox"}
+	result := rule.ExplainWindow(window)
+	if !result.TypeMatches || result.Matched {
+		t.Fatalf("result = %+v, want a window rule not matched", result)
+	}
+	if len(result.Matches) != 2 || result.Matches[0].Matched || !result.Matches[1].Matched {
+		t.Errorf("Matches = %+v, want only the second matched", result.Matches)
+	}
+	want := []FieldMatch{
+		{Field: "title", Pattern: "Bitwarden", Value: "Bitwarden - Private", Matched: true},
+		{Field: "appId", Pattern: "firefox", Value: "firefox", Matched: true},
+	}
+	if !slices.Equal(result.Matches[1].Fields, want) {
+		t.Errorf("Fields = %+v, want %+v", result.Matches[1].Fields, want)
+	}
+	if len(result.Excludes) != 2 || result.Excludes[0].Matched || !result.Excludes[1].Matched {
+		t.Errorf("Excludes = %+v, want only the second matched", result.Excludes)
+	}
+	if result.Excludes[0].Fields[0].Error == "" {
+		t.Errorf("Excludes[0] = %+v, want an invalid pattern", result.Excludes[0])
+	}
+	if rule.WindowMatches(window) != result.Matched {
+		t.Errorf("WindowMatches() = %v, want %v", rule.WindowMatches(window), result.Matched)
+	}
+
+	if got := (Match{}).ExplainWindow(window); got.Matched || len(got.Fields) != 0 {
+		t.Errorf("empty match = %+v, want not matched without fields", got)
+	}
+	workspaceRule := Rule{Type: "workspace"}
+	if got := workspaceRule.ExplainWindow(window); got.TypeMatches || got.Matched {
+		t.Errorf("workspace rule = %+v, want the type not matched", got)
+	}
+}
+
+func TestExplainWorkspace(t *testing.T) {
+	rule := &Rule{Type: "workspace", Match: []Match{{Name: "^work$", Output: "DP-1"}}}
+	workspace := Workspace{ID: 1, Name: "work", Output: "eDP-1"}
+	result := rule.ExplainWorkspace(workspace)
+	if !result.TypeMatches || !result.Matched {
+		t.Fatalf("result = %+v, want matched", result)
+	}
+	want := []FieldMatch{
+		{Field: "name", Pattern: "^work$", Value: "work", Matched: true},
+		{Field: "output", Pattern: "DP-1", Value: "eDP-1", Matched: true},
+	}
+	if !slices.Equal(result.Matches[0].Fields, want) {
+		t.Errorf("Fields = %+v, want %+v", result.Matches[0].Fields, want)
+	}
+
+	windowRule := &Rule{Match: []Match{{Name: "work"}}}
+	if got := windowRule.ExplainWorkspace(workspace); got.TypeMatches {
+		t.Errorf("window rule = %+v, want the type not matched", got)
+	}
+}